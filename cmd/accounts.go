@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fantomrocks-api/internal/models"
+	dbRepo "fantomrocks-api/internal/repository/db"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Run accounts related command.
+func accountsCommand(opt *options, args []string) error {
+	switch {
+	case 1 == len(args) && "list" == args[0]:
+		return withDataStore(opt, listAccounts)
+	case 2 == len(args) && "import" == args[0]:
+		return withDataStore(opt, func(db dbRepo.DataStore) error {
+			return importAccounts(db, args[1])
+		})
+	default:
+		return fmt.Errorf("usage: accounts list | import <file.csv>")
+	}
+}
+
+// Run the function with data store opened using the configuration.
+func withDataStore(opt *options, fn func(db dbRepo.DataStore) error) error {
+	cfg, err := opt.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	db, err := dbRepo.NewDB(cfg, services.NewLogger(cfg))
+	if err != nil {
+		return fmt.Errorf("can not open database; %s", err.Error())
+	}
+	return fn(db)
+}
+
+// Print the list of all the accounts.
+func listAccounts(db dbRepo.DataStore) error {
+	accounts, err := db.AllAccounts(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// write the table
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tADDRESS")
+	for _, acc := range accounts {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", acc.Id, acc.Name, acc.Address)
+	}
+	return w.Flush()
+}
+
+// Import accounts from the CSV file; accounts with already known address are skipped.
+func importAccounts(db dbRepo.DataStore, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// read the records
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 3
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	var added, skipped int
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("can not read accounts; %s", err.Error())
+		}
+
+		// check the record
+		acc := &models.Account{Name: strings.TrimSpace(rec[0]), Address: strings.TrimSpace(rec[1]), Password: rec[2]}
+		if "" == acc.Name {
+			return fmt.Errorf("record %d: account name is required", line)
+		}
+		if !common.IsHexAddress(acc.Address) {
+			return fmt.Errorf("record %d: invalid address %q", line, acc.Address)
+		}
+
+		// add the account
+		ok, err := db.AddAccount(context.Background(), acc)
+		if err != nil {
+			return fmt.Errorf("record %d: %s", line, err.Error())
+		}
+		if ok {
+			added++
+		} else {
+			skipped++
+		}
+	}
+
+	fmt.Printf("Imported %d accounts, %d already known.\n", added, skipped)
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Run configuration related command.
+func configCommand(opt *options, args []string) error {
+	if 1 != len(args) || "check" != args[0] {
+		return fmt.Errorf("usage: config check")
+	}
+
+	// load the configuration; all the problems are reported by the error
+	if _, err := opt.loadConfig(); err != nil {
+		return err
+	}
+
+	fmt.Println("Configuration is valid.")
+	return nil
+}
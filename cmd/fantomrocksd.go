@@ -2,29 +2,92 @@ package main
 
 import (
 	"fantomrocks-api/internal/common"
-	"fantomrocks-api/internal/handlers"
-	"fantomrocks-api/internal/repository"
-	"fantomrocks-api/internal/services"
-	"net/http"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
+// Define a command of the daemon CLI.
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   func(opt *options, args []string) error
+}
+
+// Define global options shared by all the commands.
+type options struct {
+	configPath string
+	logLevel   string
+}
+
+// list of commands available; the first one is used if none is given
+var commands = []*command{
+	{name: "serve", usage: "Start the API server.", run: serve},
+	{name: "migrate", args: "[status | up [<version>] | down <version>]", usage: "Show, upgrade or roll back the database schema version; upgrades to the latest by default.", run: migrateCommand},
+	{name: "seed", args: "[<count>]", usage: "Create, fund and pair test accounts as set by the seed options; repeated runs keep what is in place.", run: seedCommand},
+	{name: "config", args: "check", usage: "Validate the configuration and report all the problems found.", run: configCommand},
+	{name: "accounts", args: "list | import <file.csv>", usage: "List accounts, or import them from CSV file with name,address,password records.", run: accountsCommand},
+	{name: "version", usage: "Show version of the daemon.", run: versionCommand},
+}
+
 // Fantom Rocks API daemon serves GraphQL requests and provides details about Fantom transactions
-// in the Opera/XAR block chain.
+// in the Opera/XAR block chain. Other commands help operators with routine tasks.
 func main() {
-	// load config and construct the server shared environment
-	cfg := common.LoadConfig()
-	log := services.NewLogger(cfg)
-
-	// create repository
-	repo, err := repository.NewRepository(cfg, log)
-	if err != nil {
-		log.Fatalf("Can not create application data repository. Terminating!")
+	// parse global options
+	opt := new(options)
+	flag.StringVar(&opt.configPath, "config", "", "path of the configuration file (default config.yml in $HOME/.fantomrocks, or the current directory)")
+	flag.StringVar(&opt.logLevel, "log-level", "", "logging level overriding the configuration (CRITICAL, ERROR, WARNING, NOTICE, INFO, DEBUG)")
+	flag.Usage = usage
+	flag.Parse()
+
+	// which command to run
+	args := flag.Args()
+	cmd := commands[0]
+	if 0 < len(args) {
+		cmd = findCommand(args[0])
+		args = args[1:]
+	}
+	if cmd == nil {
+		usage()
+		os.Exit(2)
 	}
 
-	// setup GraphQL API handler
-	http.Handle("/api", handlers.ApiHandler(cfg, repo, log))
+	// run it
+	if err := cmd.run(opt, args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// Find command by the name.
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
 
-	// show the server opening info and start the server with DefaultServeMux
-	log.Infof("Welcome to Fantom Rocks API server on [%s]", cfg.BindAddr)
-	log.Fatal(http.ListenAndServe(cfg.BindAddr, nil))
+// Print usage of the daemon.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [options] [command]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %s\n        %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.usage)
+	}
+	fmt.Fprintf(out, "\nOptions:\n")
+	flag.PrintDefaults()
+}
+
+// Load the configuration applying the global options.
+func (opt *options) loadConfig() (*common.Config, error) {
+	overrides := make(map[string]interface{})
+	if "" != opt.logLevel {
+		overrides["logger.level"] = opt.logLevel
+	}
+	return common.LoadConfig(opt.configPath, overrides)
 }
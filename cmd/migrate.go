@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	dbRepo "fantomrocks-api/internal/repository/db"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	"strconv"
+)
+
+// Run database schema migration command.
+func migrateCommand(opt *options, args []string) error {
+	// parse the arguments
+	action, target := "up", dbRepo.LatestSchemaVersion()
+	if 0 < len(args) {
+		action = args[0]
+	}
+	switch {
+	case "status" == action && 1 == len(args):
+	case "up" == action && 1 >= len(args):
+	case ("up" == action || "down" == action) && 2 == len(args):
+		v, err := strconv.Atoi(args[1])
+		if err != nil || 0 > v {
+			return fmt.Errorf("invalid schema version %q", args[1])
+		}
+		target = v
+	default:
+		return fmt.Errorf("usage: migrate [status | up [<version>] | down <version>]")
+	}
+
+	// open the database
+	cfg, err := opt.loadConfig()
+	if err != nil {
+		return err
+	}
+	db, err := dbRepo.OpenDB(cfg, services.NewLogger(cfg))
+	if err != nil {
+		return fmt.Errorf("can not open database; %s", err.Error())
+	}
+
+	// where are we
+	ctx := context.Background()
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if "status" == action {
+		fmt.Printf("Database schema version %d, latest known %d.\n", current, dbRepo.LatestSchemaVersion())
+		return nil
+	}
+
+	// make sure we go the requested direction
+	if ("up" == action && target < current) || ("down" == action && target > current) {
+		return fmt.Errorf("can not migrate %s from version %d to %d", action, current, target)
+	}
+
+	// migrate
+	if err := db.Migrate(ctx, target); err != nil {
+		return err
+	}
+	fmt.Printf("Database schema migrated from version %d to %d.\n", current, target)
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fantomrocks-api/internal/repository"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	"strconv"
+)
+
+// Provision funded and paired test accounts.
+func seedCommand(opt *options, args []string) error {
+	if 1 < len(args) {
+		return fmt.Errorf("usage: seed [<count>]")
+	}
+
+	// load the configuration
+	cfg, err := opt.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// how many accounts
+	count := cfg.SeedCount
+	if 1 == len(args) {
+		if count, err = strconv.Atoi(args[0]); err != nil || 0 > count {
+			return fmt.Errorf("invalid number of accounts %q", args[0])
+		}
+	}
+
+	// make the repository
+	repo, err := repository.NewRepository(cfg, services.NewLogger(cfg))
+	if err != nil {
+		return fmt.Errorf("can not create application data repository; %s", err.Error())
+	}
+	defer repo.Close()
+
+	// seed
+	report, err := repo.Seed(context.Background(), cfg, count)
+	fmt.Printf("Seeded %d accounts; %d created, %d funded, %d pairs added.\n", count, report.Created, report.Funded, report.Paired)
+	return err
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/handlers"
+	"fantomrocks-api/internal/repository"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// how long requests in progress can take to finish once the server is asked to stop
+const serverShutdownTimeout = 30 * time.Second
+
+// Start the API server and serve requests until SIGINT or SIGTERM is received.
+func serve(opt *options, args []string) error {
+	if 0 < len(args) {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+
+	// load config and construct the server shared environment
+	cfg, err := opt.loadConfig()
+	if err != nil {
+		return fmt.Errorf("Can not load configuration. Terminating!\n%s", err.Error())
+	}
+	log := services.NewLogger(cfg)
+
+	// setup tracing; buffered spans are exported once everything else is closed
+	shutdownTracing, err := services.InitTracing(cfg, log)
+	if err != nil {
+		return fmt.Errorf("Can not initialize tracing. Terminating!\n%s", err.Error())
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			log.Errorf("Spans not exported. %s", err.Error())
+		}
+	}()
+
+	// create repository
+	repo, err := repository.NewRepository(cfg, log)
+	if err != nil {
+		return fmt.Errorf("Can not create application data repository. Terminating!\n%s", err.Error())
+	}
+	defer repo.Close()
+
+	// prep configuration reloader; the logger level can be changed at runtime
+	reloader := services.NewConfigReloader(cfg, opt.loadConfig, log)
+	reloader.OnReload(func(cfg *common.Config) {
+		services.SetLogLevel(cfg.LogLevel)
+	})
+
+	// setup GraphQL API handler on its own mux; the default one collects debug handlers
+	limiter := services.NewRateLimiter(cfg)
+	defer limiter.Close()
+	mux := http.NewServeMux()
+	mux.Handle("/api", handlers.ApiHandler(cfg, repo, limiter, log, reloader))
+
+	// setup developer tools, if enabled
+	if cfg.Playground {
+		mux.Handle("/playground", handlers.PlaygroundHandler(log, "/api"))
+	}
+	if cfg.SchemaExport {
+		mux.Handle("/schema.graphql", handlers.SchemaHandler(log))
+	}
+
+	// serve runtime stats on the admin listener, if enabled
+	var admin *http.Server
+	if "" != cfg.AdminBindAddr {
+		admin = serveAdmin(cfg.AdminBindAddr, log)
+	}
+
+	// reload the configuration on SIGHUP
+	reloader.WatchSignal()
+
+	// stop on SIGINT and SIGTERM
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	// show the server opening info and start the server
+	log.Infof("Welcome to Fantom Rocks API server on [%s]", cfg.BindAddr)
+	srv := &http.Server{Addr: cfg.BindAddr, Handler: mux}
+	failed := make(chan error, 1)
+	go func() {
+		failed <- srv.ListenAndServe()
+	}()
+
+	// wait for the server to fail, or to be asked to stop
+	select {
+	case err := <-failed:
+		return fmt.Errorf("API server failed. Terminating!\n%s", err.Error())
+	case sig := <-stop:
+		log.Noticef("Signal %s received, shutting down.", sig)
+	}
+
+	// let requests in progress finish; the repository and limiter are closed once we return
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+
+	if admin != nil {
+		if err := admin.Shutdown(ctx); err != nil {
+			log.Errorf("Admin end point not closed. %s", err.Error())
+		}
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("API server not closed properly. %s", err.Error())
+	}
+
+	log.Noticef("API server closed.")
+	return nil
+}
+
+// Serve runtime stats (/debug/vars) on the admin address; it should never be reachable from the public network.
+// The returned server is shut down with the API server.
+func serveAdmin(addr string, log services.Logger) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	log.Noticef("Admin end point listening on [%s]", addr)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Admin end point failed. %s", err.Error())
+		}
+	}()
+	return srv
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// version of the daemon; set on build by -ldflags "-X main.version=..."
+var version = "dev"
+
+// Show version of the daemon.
+func versionCommand(_ *options, _ []string) error {
+	fmt.Printf("Fantom Rocks API daemon %s (%s %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
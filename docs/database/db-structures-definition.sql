@@ -12,6 +12,9 @@ DROP SEQUENCE IF EXISTS seq_account
 DROP SEQUENCE IF EXISTS seq_account_pair
 ;
 
+DROP SEQUENCE IF EXISTS seq_api_key
+;
+
 /* Drop Tables */
 
 DROP TABLE IF EXISTS account CASCADE
@@ -20,6 +23,9 @@ DROP TABLE IF EXISTS account CASCADE
 DROP TABLE IF EXISTS account_pair CASCADE
 ;
 
+DROP TABLE IF EXISTS api_key CASCADE
+;
+
 /* Create Tables */
 
 CREATE TABLE account
@@ -39,6 +45,16 @@ CREATE TABLE account_pair
 )
 ;
 
+CREATE TABLE api_key
+(
+	id bigint NOT NULL   DEFAULT NEXTVAL(('seq_api_key'::text)::regclass),
+	name varchar(50) NOT NULL,
+	key_hash varchar(64) NOT NULL,
+	roles varchar(100) NOT NULL   DEFAULT 'operator',
+	active boolean NOT NULL   DEFAULT true
+)
+;
+
 /* Create Primary Keys, Indexes, Uniques, Checks */
 
 ALTER TABLE account ADD CONSTRAINT "PK_account"
@@ -58,6 +74,13 @@ CREATE INDEX "IXFK_account_pair_account" ON account_pair (account_id_left ASC)
 CREATE INDEX "IXFK_account_pair_account_02" ON account_pair (account_id_right ASC)
 ;
 
+ALTER TABLE api_key ADD CONSTRAINT "PK_api_key"
+	PRIMARY KEY (id)
+;
+
+CREATE UNIQUE INDEX "IX_api_key_hash" ON api_key (key_hash ASC)
+;
+
 /* Create Foreign Key Constraints */
 
 ALTER TABLE account_pair ADD CONSTRAINT "FK_account_pair_account"
@@ -79,3 +102,10 @@ COMMENT ON TABLE account_pair
 
 CREATE SEQUENCE seq_account_pair INCREMENT 1 START 1
 ;
+
+CREATE SEQUENCE seq_api_key INCREMENT 1 START 1
+;
+
+COMMENT ON TABLE api_key
+	IS 'API keys of callers allowed to use mutations; only SHA-256 hashes of the keys are stored.'
+;
@@ -1,8 +1,20 @@
 package common
 
 import (
+	"fmt"
 	"github.com/spf13/viper"
 	"log"
+	"strings"
+	"time"
+)
+
+// prefix of environment variables overriding the configuration
+const envPrefix = "FANTOMROCKS"
+
+// schemes of RPC end points selecting the simulated block chain, and replay of recorded calls
+const (
+	SimulatedChainScheme = "sim://"
+	ReplayChainScheme    = "replay://"
 )
 
 // Structure describes configuration options for Crystal API server.
@@ -12,9 +24,29 @@ type Config struct {
 	BindAddr string
 	Cors     []string
 
+	// address of the admin listener serving runtime stats; empty disables it
+	AdminBindAddr string
+
+	// proxies allowed to pass client address in X-Forwarded-For header
+	TrustedProxies []string
+
+	// developer tools
+	Playground   bool
+	SchemaExport bool
+
 	// logger specific options
 	LogLevel  string
 	LogFormat string
+	LogJson   bool
+
+	// access log level; empty disables the access log
+	AccessLogLevel string
+
+	// tracing
+	TracingExporter    string
+	TracingEndpoint    string
+	TracingInsecure    bool
+	TracingSampleRatio float64
 
 	// database configuration details
 	DbDriver             string
@@ -24,76 +56,327 @@ type Config struct {
 	DbUser               string
 	DbPassword           string
 	DbMaxOpenConnections int
+	DbTimeout            time.Duration
+	DbRetries            int
+	DbRetryBackoff       time.Duration
+
+	// fixture file loaded to the in-memory data store
+	DbFixture string
 
 	// RPC connection to the related block chain node
-	RpcUrl string
+	RpcUrl            string
+	RpcUrls           []string
+	RpcWriteUrl       string
+	RpcMaxLag         uint64
+	RpcHealthInterval time.Duration
+	RpcTimeout        time.Duration
+	RpcRetries        int
+	RpcRetryBackoff   time.Duration
+	RpcBatchWait      time.Duration
+	RpcBatchSize      int
+	RpcCacheSize      int
+
+	// fixture file recording all the RPC calls
+	RpcRecord string
+
+	// simulated block chain used with the sim:// end point
+	RpcSimBlockTime time.Duration
+	RpcSimBalance   float64
+
+	// shared balances cache
+	BalanceTTL     time.Duration
+	BalanceOnBlock bool
+
+	// API authentication options
+	AuthJwksFile   string
+	AuthIssuer     string
+	AuthAudience   string
+	AuthRolesClaim string
+
+	// rate limiting of API clients
+	RateLimitRequests       float64
+	RateLimitRequestsBurst  int
+	RateLimitTransfers      float64
+	RateLimitTransfersBurst int
+
+	// GraphQL queries limits
+	MaxQueryDepth int
+	MaxQueryCost  int
+
+	// automatic persisted queries
+	PersistedCacheSize int
+	PersistedAllowlist string
+	PersistedStrict    bool
+
+	// GraphQL over HTTP options
+	MaxBatchSize   int
+	GetCacheMaxAge time.Duration
+
+	// seeding of test accounts
+	SeedCount            int
+	SeedPrefix           string
+	SeedAmount           float64
+	SeedPairing          string
+	SeedKeystore         string
+	SeedTreasuryAddress  string
+	SeedTreasuryPassword string
 }
 
 // Define Context key for configuration access.
 type ConfigContextKey struct{}
 
+// Define Context key for authenticated caller identity access.
+type IdentityContextKey struct{}
+
+// Define Context key for API client rate limit quota access.
+type QuotaContextKey struct{}
+
+// Define Context key for the requests quota of the client address.
+type RequestQuotaContextKey struct{}
+
+// Define Context key for request scoped data loaders access.
+type LoadersContextKey struct{}
+
+// Define Context key for the request ID access.
+type RequestIdContextKey struct{}
+
+// Define Context key for the access log record of the request.
+type AccessLogContextKey struct{}
+
 // default configuration options
 var defaults = map[string]interface{}{
-	"server.name": "FantomRocksApi",
-	"server.cors": []string{"*"},
+	"server.name":       "FantomRocksApi",
+	"server.listen":     ":8084",
+	"server.playground": false,
+	"server.schema":     false,
+	"server.cors":       []string{"*"},
+	"server.proxies":    []string{},
+	"server.admin":      "",
 
 	"logger.level":  "INFO",
+	"logger.json":   false,
+	"logger.access": "INFO",
 	"logger.format": "%{color}%{time:2019-01-01 15:04:05} [%{level:.6s}] %{shortfunc}:%{color:reset} %{message}",
 
-	"db.driver":    "postgres",
-	"db.host":      "localhost",
-	"db.port":      "8084",
-	"db.name":      "fantom",
-	"db.user":      "default-user",
-	"db.password":  "default-password",
-	"db.pool_size": "10",
+	"tracing.exporter":     "",
+	"tracing.endpoint":     "localhost:4318",
+	"tracing.insecure":     false,
+	"tracing.sample_ratio": 1.0,
+
+	"db.driver":        "postgres",
+	"db.host":          "localhost",
+	"db.port":          "5432",
+	"db.name":          "fantom",
+	"db.user":          "default-user",
+	"db.password":      "default-password",
+	"db.pool_size":     10,
+	"db.timeout":       "5s",
+	"db.retries":       2,
+	"db.retry_backoff": "100ms",
+	"db.fixture":       "",
+
+	"rpc.url":             "~/.lachesis/data/lachesis.ipc",
+	"rpc.urls":            []string{},
+	"rpc.write_url":       "",
+	"rpc.max_lag":         5,
+	"rpc.health_interval": "5s",
+	"rpc.timeout":         "10s",
+	"rpc.retries":         2,
+	"rpc.retry_backoff":   "100ms",
+	"rpc.batch_wait":      "2ms",
+	"rpc.batch_size":      100,
+	"rpc.cache_size":      10000,
+	"rpc.record":          "",
+
+	"rpc.sim_block_time": "1s",
+	"rpc.sim_balance":    1000,
 
-	"rpc.url": "~/.lachesis/data/lachesis.ipc",
+	"rpc.balance_ttl":      "2s",
+	"rpc.balance_on_block": false,
+
+	"auth.jwks":        "",
+	"auth.issuer":      "",
+	"auth.audience":    "",
+	"auth.roles_claim": "roles",
+
+	"ratelimit.requests":        10,
+	"ratelimit.requests_burst":  20,
+	"ratelimit.transfers":       0.1,
+	"ratelimit.transfers_burst": 25,
+
+	"graphql.max_depth": 8,
+	"graphql.max_cost":  1000,
+
+	"graphql.persisted_cache_size": 1000,
+	"graphql.persisted_allowlist":  "",
+	"graphql.persisted_strict":     false,
+
+	"graphql.max_batch":         10,
+	"graphql.get_cache_max_age": "0s",
+
+	"seed.count":             10,
+	"seed.prefix":            "seed-",
+	"seed.amount":            0,
+	"seed.pairing":           "sequential",
+	"seed.keystore":          "",
+	"seed.treasury_address":  "",
+	"seed.treasury_password": "",
 }
 
 // Function provides loaded configuration for Crystal API server.
-func LoadConfig() *Config {
+// The file is read from the given path, if set; config.yml is looked up in $HOME/.fantomrocks
+// and the current directory otherwise, and it's optional in that case.
+// Each option can be overridden by FANTOMROCKS_<SECTION>_<KEY> environment variable
+// (i.e. FANTOMROCKS_DB_HOST for db.host), overrides passed by the caller take precedence
+// over all the other sources. All invalid values are reported together in the returned error.
+func LoadConfig(path string, overrides map[string]interface{}) (*Config, error) {
 	cfg := viper.New()
 
-	// what is the expected name of the common file
-	cfg.SetConfigName("config")
+	// do we have an explicit file to read?
+	if "" != path {
+		cfg.SetConfigFile(path)
+	} else {
+		// what is the expected name of the common file
+		cfg.SetConfigName("config")
+
+		// where to look for common files
+		cfg.AddConfigPath("$HOME/.fantomrocks")
+		cfg.AddConfigPath(".")
+	}
 
-	// where to look for common files
-	cfg.AddConfigPath("$HOME/.fantomrocks")
-	cfg.AddConfigPath(".")
+	// environment variables override the file
+	cfg.SetEnvPrefix(envPrefix)
+	cfg.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	cfg.AutomaticEnv()
 
 	// set default values
 	applyDefaults(cfg)
 
-	// try to read the file
-	err := cfg.ReadInConfig()
-	if err != nil {
-		log.Fatalf("Fatal error reading configuration file: %s\n", err)
+	// try to read the file; we can live without it if the environment provides the values
+	if err := cfg.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, fmt.Errorf("can not read configuration file; %s", err.Error())
+		}
+		log.Printf("No configuration file found, using defaults and environment.")
+	}
+
+	// apply the caller overrides
+	for key, value := range overrides {
+		cfg.Set(key, value)
 	}
 
-	// build and return the common struct
-	return &Config{
+	// build the common struct
+	r := &configReader{Viper: cfg}
+	c := &Config{
 		// basics
-		AppName:  cfg.GetString("server.name"),
-		BindAddr: cfg.GetString("server.listen"),
-		Cors:     cfg.GetStringSlice("server.cors"),
+		AppName:  r.GetString("server.name"),
+		BindAddr: r.GetString("server.listen"),
+		Cors:     r.list("server.cors"),
+
+		// trusted proxies
+		TrustedProxies: r.list("server.proxies"),
+
+		// admin listener
+		AdminBindAddr: r.GetString("server.admin"),
+
+		// developer tools
+		Playground:   r.flag("server.playground"),
+		SchemaExport: r.flag("server.schema"),
 
 		// logger
-		LogLevel:  cfg.GetString("logger.level"),
-		LogFormat: cfg.GetString("logger.format"),
+		LogLevel:  r.GetString("logger.level"),
+		LogFormat: r.GetString("logger.format"),
+		LogJson:   r.flag("logger.json"),
+
+		// access log
+		AccessLogLevel: r.GetString("logger.access"),
+
+		// tracing
+		TracingExporter:    r.GetString("tracing.exporter"),
+		TracingEndpoint:    r.GetString("tracing.endpoint"),
+		TracingInsecure:    r.flag("tracing.insecure"),
+		TracingSampleRatio: r.number("tracing.sample_ratio"),
 
 		// db connection details
-		DbDriver:             cfg.GetString("db.driver"),
-		DbHost:               cfg.GetString("db.host"),
-		DbPort:               cfg.GetString("db.port"),
-		DbName:               cfg.GetString("db.name"),
-		DbUser:               cfg.GetString("db.user"),
-		DbPassword:           cfg.GetString("db.password"),
-		DbMaxOpenConnections: cfg.GetInt("db.pool_size"),
+		DbDriver:             r.GetString("db.driver"),
+		DbHost:               r.GetString("db.host"),
+		DbPort:               r.GetString("db.port"),
+		DbName:               r.GetString("db.name"),
+		DbUser:               r.GetString("db.user"),
+		DbPassword:           r.GetString("db.password"),
+		DbMaxOpenConnections: r.integer("db.pool_size"),
+		DbTimeout:            r.duration("db.timeout"),
+		DbRetries:            r.integer("db.retries"),
+		DbRetryBackoff:       r.duration("db.retry_backoff"),
+		DbFixture:            r.GetString("db.fixture"),
 
 		// RPC related
-		RpcUrl: cfg.GetString("rpc.url"),
+		RpcUrl:            r.GetString("rpc.url"),
+		RpcUrls:           r.list("rpc.urls"),
+		RpcWriteUrl:       r.GetString("rpc.write_url"),
+		RpcMaxLag:         r.unsigned("rpc.max_lag"),
+		RpcHealthInterval: r.duration("rpc.health_interval"),
+		RpcTimeout:        r.duration("rpc.timeout"),
+		RpcRetries:        r.integer("rpc.retries"),
+		RpcRetryBackoff:   r.duration("rpc.retry_backoff"),
+		RpcBatchWait:      r.duration("rpc.batch_wait"),
+		RpcBatchSize:      r.integer("rpc.batch_size"),
+		RpcCacheSize:      r.integer("rpc.cache_size"),
+		RpcRecord:         r.GetString("rpc.record"),
+
+		// simulated block chain
+		RpcSimBlockTime: r.duration("rpc.sim_block_time"),
+		RpcSimBalance:   r.number("rpc.sim_balance"),
+
+		// balances cache
+		BalanceTTL:     r.duration("rpc.balance_ttl"),
+		BalanceOnBlock: r.flag("rpc.balance_on_block"),
+
+		// authentication
+		AuthJwksFile:   r.GetString("auth.jwks"),
+		AuthIssuer:     r.GetString("auth.issuer"),
+		AuthAudience:   r.GetString("auth.audience"),
+		AuthRolesClaim: r.GetString("auth.roles_claim"),
+
+		// rate limiting
+		RateLimitRequests:       r.number("ratelimit.requests"),
+		RateLimitRequestsBurst:  r.integer("ratelimit.requests_burst"),
+		RateLimitTransfers:      r.number("ratelimit.transfers"),
+		RateLimitTransfersBurst: r.integer("ratelimit.transfers_burst"),
+
+		// GraphQL queries limits
+		MaxQueryDepth: r.integer("graphql.max_depth"),
+		MaxQueryCost:  r.integer("graphql.max_cost"),
+
+		// persisted queries
+		PersistedCacheSize: r.integer("graphql.persisted_cache_size"),
+		PersistedAllowlist: r.GetString("graphql.persisted_allowlist"),
+		PersistedStrict:    r.flag("graphql.persisted_strict"),
+
+		// GraphQL over HTTP
+		MaxBatchSize:   r.integer("graphql.max_batch"),
+		GetCacheMaxAge: r.duration("graphql.get_cache_max_age"),
+
+		// seeding
+		SeedCount:            r.integer("seed.count"),
+		SeedPrefix:           r.GetString("seed.prefix"),
+		SeedAmount:           r.number("seed.amount"),
+		SeedPairing:          r.GetString("seed.pairing"),
+		SeedKeystore:         r.GetString("seed.keystore"),
+		SeedTreasuryAddress:  r.GetString("seed.treasury_address"),
+		SeedTreasuryPassword: r.GetString("seed.treasury_password"),
+	}
+
+	// check the values
+	if err := r.validate(c); err != nil {
+		return nil, err
 	}
+	return c, nil
+}
+
+// Check if the simulated block chain is used instead of a real node.
+func (c *Config) IsSimulatedChain() bool {
+	return strings.HasPrefix(c.RpcUrl, SimulatedChainScheme)
 }
 
 // load default/predefined values to the configuration manager
@@ -0,0 +1,43 @@
+package common
+
+import "reflect"
+
+// settings safe to be changed while the server is running
+var reloadable = map[string]bool{
+	"LogLevel":                true,
+	"Cors":                    true,
+	"RateLimitRequests":       true,
+	"RateLimitRequestsBurst":  true,
+	"RateLimitTransfers":      true,
+	"RateLimitTransfersBurst": true,
+	"MaxQueryDepth":           true,
+	"MaxQueryCost":            true,
+	"MaxBatchSize":            true,
+}
+
+// Merge settings safe to be changed at runtime from the new configuration into a copy of this one.
+// Names of changed settings are returned split to these applied and these ignored until restart.
+func (c *Config) Merge(n *Config) (merged *Config, applied []string, ignored []string) {
+	m := *c
+	mv := reflect.ValueOf(&m).Elem()
+	nv := reflect.ValueOf(n).Elem()
+
+	// loop all the settings and compare them
+	for i := 0; i < mv.NumField(); i++ {
+		name := mv.Type().Field(i).Name
+		if reflect.DeepEqual(mv.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+
+		// can we take it?
+		if !reloadable[name] {
+			ignored = append(ignored, name)
+			continue
+		}
+
+		mv.Field(i).Set(nv.Field(i))
+		applied = append(applied, name)
+	}
+
+	return &m, applied, ignored
+}
@@ -0,0 +1,297 @@
+package common
+
+import (
+	"fmt"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/op/go-logging"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Define reader of typed configuration values collecting problems found on the way.
+type configReader struct {
+	*viper.Viper
+	problems []string
+}
+
+// Define error reporting all the problems found in the configuration.
+type ConfigError struct {
+	Problems []string
+}
+
+// Format the report of configuration problems.
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Record problem with the value of the given key.
+func (r *configReader) fail(key string, format string, args ...interface{}) {
+	env := envPrefix + "_" + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+	r.problems = append(r.problems, fmt.Sprintf("%s (%s): %s", key, env, fmt.Sprintf(format, args...)))
+}
+
+// Get duration value of the key.
+func (r *configReader) duration(key string) time.Duration {
+	val, err := cast.ToDurationE(r.Get(key))
+	if err != nil {
+		r.fail(key, "invalid duration %v", r.Get(key))
+	}
+	return val
+}
+
+// Get integer value of the key.
+func (r *configReader) integer(key string) int {
+	val, err := cast.ToIntE(r.Get(key))
+	if err != nil {
+		r.fail(key, "invalid integer %v", r.Get(key))
+	}
+	return val
+}
+
+// Get non-negative integer value of the key.
+func (r *configReader) unsigned(key string) uint64 {
+	val := r.integer(key)
+	if val < 0 {
+		r.fail(key, "must not be negative")
+		return 0
+	}
+	return uint64(val)
+}
+
+// Get floating point value of the key.
+func (r *configReader) number(key string) float64 {
+	val, err := cast.ToFloat64E(r.Get(key))
+	if err != nil {
+		r.fail(key, "invalid number %v", r.Get(key))
+	}
+	return val
+}
+
+// Get boolean value of the key.
+func (r *configReader) flag(key string) bool {
+	val, err := cast.ToBoolE(r.Get(key))
+	if err != nil {
+		r.fail(key, "invalid boolean %v", r.Get(key))
+	}
+	return val
+}
+
+// Get list of strings of the key; items can be separated by commas, or spaces in environment variables.
+func (r *configReader) list(key string) []string {
+	val, err := cast.ToStringSliceE(r.Get(key))
+	if err != nil {
+		r.fail(key, "invalid list %v", r.Get(key))
+		return nil
+	}
+
+	// split comma separated items
+	res := make([]string, 0, len(val))
+	for _, v := range val {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); "" != item {
+				res = append(res, item)
+			}
+		}
+	}
+	return res
+}
+
+// Check values of the configuration; all problems found, including these found while reading, are reported.
+func (r *configReader) validate(c *Config) error {
+	// server
+	r.required("server.name", c.AppName)
+	if _, _, err := net.SplitHostPort(c.BindAddr); err != nil {
+		r.fail("server.listen", "invalid listen address %q, expected host:port", c.BindAddr)
+	}
+	if "" != c.AdminBindAddr {
+		if _, _, err := net.SplitHostPort(c.AdminBindAddr); err != nil {
+			r.fail("server.admin", "invalid listen address %q, expected host:port", c.AdminBindAddr)
+		} else if c.AdminBindAddr == c.BindAddr {
+			r.fail("server.admin", "must not be the same as server.listen")
+		}
+	}
+	for _, p := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+			r.fail("server.proxies", "invalid address or range %q", p)
+		}
+	}
+
+	// logger
+	if _, err := logging.LogLevel(c.LogLevel); err != nil {
+		r.fail("logger.level", "unknown level %q", c.LogLevel)
+	}
+	if _, err := logging.LogLevel(c.AccessLogLevel); err != nil && "" != c.AccessLogLevel && !strings.EqualFold(c.AccessLogLevel, "NONE") {
+		r.fail("logger.access", "unknown level %q", c.AccessLogLevel)
+	}
+	if !c.LogJson {
+		r.required("logger.format", c.LogFormat)
+	}
+
+	// tracing
+	switch c.TracingExporter {
+	case "", "stdout":
+	case "otlp":
+		r.required("tracing.endpoint", c.TracingEndpoint)
+	default:
+		r.fail("tracing.exporter", "unknown exporter %q, expected otlp or stdout", c.TracingExporter)
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		r.fail("tracing.sample_ratio", "must be between 0 and 1")
+	}
+
+	// database
+	switch c.DbDriver {
+	case "postgres":
+		r.required("db.host", c.DbHost)
+		r.required("db.name", c.DbName)
+		r.required("db.user", c.DbUser)
+		if port, err := strconv.Atoi(c.DbPort); err != nil || port <= 0 || port > 65535 {
+			r.fail("db.port", "invalid port %q", c.DbPort)
+		}
+		r.positive("db.pool_size", c.DbMaxOpenConnections)
+		r.notNegative("db.retries", c.DbRetries)
+	case "sqlite":
+		r.required("db.name", c.DbName)
+		r.positive("db.pool_size", c.DbMaxOpenConnections)
+		r.notNegative("db.retries", c.DbRetries)
+	case "memory":
+		if "" != c.DbFixture {
+			if _, err := os.Stat(c.DbFixture); err != nil {
+				r.fail("db.fixture", "can not access fixture file; %s", err.Error())
+			}
+		}
+	default:
+		r.fail("db.driver", "unknown driver %q, expected postgres, sqlite or memory", c.DbDriver)
+	}
+
+	// RPC
+	if "" == c.RpcUrl && 0 == len(c.RpcUrls) {
+		r.fail("rpc.url", "node end point is required, set rpc.url or rpc.urls")
+	}
+	r.positive("rpc.batch_size", c.RpcBatchSize)
+	r.notNegative("rpc.cache_size", c.RpcCacheSize)
+	r.notNegative("rpc.retries", c.RpcRetries)
+	if 0 >= c.RpcHealthInterval {
+		r.fail("rpc.health_interval", "must be positive")
+	}
+	for _, url := range c.rpcEndpoints() {
+		if strings.HasPrefix(url, ReplayChainScheme) {
+			if _, err := os.Stat(strings.TrimPrefix(url, ReplayChainScheme)); err != nil {
+				r.fail("rpc.url", "can not access replay fixture; %s", err.Error())
+			}
+		}
+		if "" != c.RpcRecord && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			r.fail("rpc.record", "only HTTP end points can be recorded, got %q", url)
+		}
+	}
+	if c.IsSimulatedChain() {
+		if 0 < len(c.RpcUrls) {
+			r.fail("rpc.urls", "can not be combined with simulated block chain %q", c.RpcUrl)
+		}
+		if 0 >= c.RpcSimBlockTime {
+			r.fail("rpc.sim_block_time", "must be positive")
+		}
+		if c.RpcSimBalance < 0 {
+			r.fail("rpc.sim_balance", "must not be negative")
+		}
+	}
+
+	// authentication
+	if "" != c.AuthJwksFile {
+		if _, err := os.Stat(c.AuthJwksFile); err != nil {
+			r.fail("auth.jwks", "can not access JWKS file; %s", err.Error())
+		}
+	}
+
+	// rate limits
+	if c.RateLimitRequests < 0 {
+		r.fail("ratelimit.requests", "must not be negative")
+	}
+	if c.RateLimitRequests > 0 {
+		r.positive("ratelimit.requests_burst", c.RateLimitRequestsBurst)
+	}
+	if c.RateLimitTransfers < 0 {
+		r.fail("ratelimit.transfers", "must not be negative")
+	}
+	if c.RateLimitTransfers > 0 {
+		r.positive("ratelimit.transfers_burst", c.RateLimitTransfersBurst)
+	}
+
+	// GraphQL
+	r.notNegative("graphql.max_depth", c.MaxQueryDepth)
+	r.notNegative("graphql.max_cost", c.MaxQueryCost)
+	r.notNegative("graphql.max_batch", c.MaxBatchSize)
+	r.notNegative("graphql.persisted_cache_size", c.PersistedCacheSize)
+	if c.PersistedStrict && "" == c.PersistedAllowlist {
+		r.fail("graphql.persisted_strict", "strict mode requires graphql.persisted_allowlist")
+	}
+
+	// seeding
+	r.notNegative("seed.count", c.SeedCount)
+	r.required("seed.prefix", c.SeedPrefix)
+	if c.SeedAmount < 0 {
+		r.fail("seed.amount", "must not be negative")
+	}
+	switch c.SeedPairing {
+	case "random", "sequential", "ring":
+	default:
+		r.fail("seed.pairing", "unknown strategy %q, expected random, sequential or ring", c.SeedPairing)
+	}
+	if "" != c.SeedTreasuryAddress && !ethcommon.IsHexAddress(c.SeedTreasuryAddress) {
+		r.fail("seed.treasury_address", "invalid address %q", c.SeedTreasuryAddress)
+	}
+
+	// the node signs transfers, it has to find keys of the seeded accounts in its own key store;
+	// the directory must exist so a typo doesn't make a new key store the node never reads
+	if "" != c.SeedKeystore {
+		if c.IsSimulatedChain() {
+			r.fail("seed.keystore", "the simulated chain has no key store, leave it empty")
+		} else if fi, err := os.Stat(c.SeedKeystore); err != nil || !fi.IsDir() {
+			r.fail("seed.keystore", "must be the existing key store directory of the write RPC node; %q is not a directory", c.SeedKeystore)
+		}
+	}
+
+	// anything wrong?
+	if 0 < len(r.problems) {
+		return &ConfigError{Problems: r.problems}
+	}
+	return nil
+}
+
+// Check the value is set.
+func (r *configReader) required(key string, val string) {
+	if "" == strings.TrimSpace(val) {
+		r.fail(key, "value is required")
+	}
+}
+
+// Check the value is greater than zero.
+func (r *configReader) positive(key string, val int) {
+	if val <= 0 {
+		r.fail(key, "must be greater than zero")
+	}
+}
+
+// Check the value is not negative.
+func (r *configReader) notNegative(key string, val int) {
+	if val < 0 {
+		r.fail(key, "must not be negative")
+	}
+}
+
+// Get all the RPC end points the adapter connects to.
+func (c *Config) rpcEndpoints() []string {
+	urls := append([]string{}, c.RpcUrls...)
+	if 0 == len(urls) {
+		urls = append(urls, c.RpcUrl)
+	}
+	if "" != c.RpcWriteUrl {
+		urls = append(urls, c.RpcWriteUrl)
+	}
+	return urls
+}
@@ -0,0 +1,149 @@
+package loaders
+
+import (
+	"sync"
+	"time"
+)
+
+// Define function fetching values for a batch of keys.
+// Values and errors are expected in the order of keys.
+type fetchFunc func(keys []string) ([]interface{}, []error)
+
+// Define batch loader collecting keys requested within a short time window
+// and fetching them together in a single call. Loaded values are kept
+// for the lifetime of the loader so each key is fetched only once.
+type batchLoader struct {
+	fetch    fetchFunc
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	results map[string]*result
+	pending *batch
+}
+
+// Define result of a single key load.
+type result struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// Define batch of keys waiting to be fetched.
+type batch struct {
+	keys    []string
+	results []*result
+}
+
+// Create new batch loader.
+func newBatchLoader(fetch fetchFunc, wait time.Duration, maxBatch int) *batchLoader {
+	// make sure we collect at least something
+	if 1 > maxBatch {
+		maxBatch = 1
+	}
+
+	return &batchLoader{
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		results:  make(map[string]*result),
+	}
+}
+
+// Load value for the given key; waits for the batch the key belongs to.
+func (l *batchLoader) load(key string) (interface{}, error) {
+	r := l.enqueue(key)
+	<-r.done
+	return r.value, r.err
+}
+
+// Load values for a list of keys.
+// We know all the keys so the pending batch is dispatched right away.
+func (l *batchLoader) loadMany(keys []string) ([]interface{}, []error) {
+	// add all the keys
+	rs := make([]*result, len(keys))
+	for i, k := range keys {
+		rs[i] = l.enqueue(k)
+	}
+
+	// no need to wait for more keys
+	l.flush()
+
+	// collect the results
+	values := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	for i, r := range rs {
+		<-r.done
+		values[i], errs[i] = r.value, r.err
+	}
+
+	return values, errs
+}
+
+// Add the key to the pending batch, unless it's already known.
+func (l *batchLoader) enqueue(key string) *result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// do we know the key already?
+	if r, ok := l.results[key]; ok {
+		return r
+	}
+
+	// make new result container
+	r := &result{done: make(chan struct{})}
+	l.results[key] = r
+
+	// start new batch if needed; it will be dispatched when the wait time is over
+	if l.pending == nil {
+		b := new(batch)
+		l.pending = b
+		time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+
+	// add the key
+	l.pending.keys = append(l.pending.keys, key)
+	l.pending.results = append(l.pending.results, r)
+
+	// is the batch full?
+	if len(l.pending.keys) >= l.maxBatch {
+		go l.run(l.pending)
+		l.pending = nil
+	}
+
+	return r
+}
+
+// Dispatch the given batch, unless it has been dispatched already.
+func (l *batchLoader) dispatch(b *batch) {
+	l.mu.Lock()
+	if l.pending != b {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+
+	l.run(b)
+}
+
+// Dispatch the pending batch right away.
+func (l *batchLoader) flush() {
+	l.mu.Lock()
+	b := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	if b != nil {
+		l.run(b)
+	}
+}
+
+// Fetch the batch and deliver the results to waiting callers.
+func (l *batchLoader) run(b *batch) {
+	values, errs := l.fetch(b.keys)
+	for i, r := range b.results {
+		r.value, r.err = values[i], errs[i]
+		close(r.done)
+	}
+}
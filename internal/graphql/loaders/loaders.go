@@ -0,0 +1,78 @@
+package loaders
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/repository"
+	"time"
+)
+
+// Define set of data loaders of a single API request.
+type Loaders struct {
+	balances     *batchLoader
+	transactions *batchLoader
+}
+
+// Create new set of data loaders for a single API request.
+// Batches are loaded within the context of the request.
+func New(ctx context.Context, repo *repository.Repository, wait time.Duration, maxBatch int) *Loaders {
+	return &Loaders{
+		balances: newBatchLoader(func(keys []string) ([]interface{}, []error) {
+			bal, errs := repo.Rpc.AccountBalances(ctx, keys)
+			values := make([]interface{}, len(bal))
+			for i, b := range bal {
+				values[i] = b
+			}
+			return values, errs
+		}, wait, maxBatch),
+
+		transactions: newBatchLoader(func(keys []string) ([]interface{}, []error) {
+			trx, errs := repo.Rpc.TransactionsByHash(ctx, keys)
+			values := make([]interface{}, len(trx))
+			for i, t := range trx {
+				values[i] = t
+			}
+			return values, errs
+		}, wait, maxBatch),
+	}
+}
+
+// Attach the loaders to the given context.
+func (l *Loaders) Attach(ctx context.Context) context.Context {
+	return context.WithValue(ctx, common.LoadersContextKey{}, l)
+}
+
+// Get Account balance using the request loader, if available.
+func Balance(ctx context.Context, repo *repository.Repository, addr string) (*models.Amount, error) {
+	// no loader, no batching
+	l, ok := ctx.Value(common.LoadersContextKey{}).(*Loaders)
+	if !ok {
+		return repo.Rpc.AccountBalance(ctx, addr)
+	}
+
+	// make sure we always have a value
+	val, err := l.balances.load(addr)
+	bal, ok := val.(*models.Amount)
+	if !ok {
+		bal = &models.Amount{}
+	}
+	return bal, err
+}
+
+// Get list of Transactions using the request loader, if available.
+func Transactions(ctx context.Context, repo *repository.Repository, hashes []string) ([]*models.BcTransaction, []error) {
+	// no loader, no batching
+	l, ok := ctx.Value(common.LoadersContextKey{}).(*Loaders)
+	if !ok {
+		return repo.Rpc.TransactionsByHash(ctx, hashes)
+	}
+
+	// load the values and convert them
+	values, errs := l.transactions.loadMany(hashes)
+	trx := make([]*models.BcTransaction, len(values))
+	for i, v := range values {
+		trx[i], _ = v.(*models.BcTransaction)
+	}
+	return trx, errs
+}
@@ -1,28 +1,29 @@
 package resolvers
 
 import (
+	"context"
 	"fantomrocks-api/internal/graphql/types"
 	"github.com/graph-gophers/graphql-go"
 	"strconv"
 )
 
 // Implements Query.account for an Account specified by ID or random
-func (rs *Resolver) Account(args *struct{ Id *graphql.ID }) (*types.Account, error) {
+func (rs *Resolver) Account(ctx context.Context, args *struct{ Id *graphql.ID }) (*types.Account, error) {
 	// random account requested?
 	if args == nil || args.Id == nil {
-		return rs.RandomAccount()
+		return rs.RandomAccount(ctx)
 	}
 
 	// get the account id
 	id, err := strconv.Atoi(string(*args.Id))
 	if err != nil {
-		rs.log.Errorf("GQL->Mutation->Account(): Invalid account ID [%s]. %s", args.Id, err.Error())
+		rs.logger(ctx).Errorf("GQL->Mutation->Account(): Invalid account ID [%s]. %s", args.Id, err.Error())
 		return nil, err
 	}
 
-	acc, err := rs.Db.AccountById(id)
+	acc, err := rs.Db.AccountById(ctx, id)
 	if err != nil {
-		rs.log.Errorf("GQL->Query->Account(): Can not get Account. %s", err.Error())
+		rs.logger(ctx).Errorf("GQL->Query->Account(): Can not get Account. %s", err.Error())
 		return nil, err
 	}
 
@@ -30,11 +31,11 @@ func (rs *Resolver) Account(args *struct{ Id *graphql.ID }) (*types.Account, err
 }
 
 // Implements Query.account for a random Account
-func (rs *Resolver) RandomAccount() (*types.Account, error) {
+func (rs *Resolver) RandomAccount(ctx context.Context) (*types.Account, error) {
 	// get random account info from DB
-	acc, err := rs.Db.RandomAccount()
+	acc, err := rs.Db.RandomAccount(ctx)
 	if err != nil {
-		rs.log.Errorf("GQL->Query->RandomAccount(): Can not get a random Account. %s", err.Error())
+		rs.logger(ctx).Errorf("GQL->Query->RandomAccount(): Can not get a random Account. %s", err.Error())
 		return nil, err
 	}
 
@@ -42,14 +43,14 @@ func (rs *Resolver) RandomAccount() (*types.Account, error) {
 }
 
 // Implements Query.accounts GraphQL entry point
-func (rs *Resolver) Accounts(args *struct{ List *[]graphql.ID }) ([]*types.Account, error) {
+func (rs *Resolver) Accounts(ctx context.Context, args *struct{ List *[]graphql.ID }) ([]*types.Account, error) {
 	// all accounts, or specified subset?
 	if args == nil || args.List == nil {
-		return rs.AllAccounts()
+		return rs.AllAccounts(ctx)
 	}
 
 	// log the action
-	rs.log.Debugf("GQL->Query->Accounts(): List of specified accounts is constructed.")
+	rs.logger(ctx).Debugf("GQL->Query->Accounts(): List of specified accounts is constructed.")
 
 	// loop requested accounts and construct the response
 	result := make([]*types.Account, 0)
@@ -57,14 +58,14 @@ func (rs *Resolver) Accounts(args *struct{ List *[]graphql.ID }) ([]*types.Accou
 		// try to convert incoming account id
 		id, err := strconv.Atoi(string(aid))
 		if err != nil {
-			rs.log.Debugf("GQL->Query->Accounts(): Invalid incoming account id '%s'.", aid)
+			rs.logger(ctx).Debugf("GQL->Query->Accounts(): Invalid incoming account id '%s'.", aid)
 			continue
 		}
 
 		// try to find the account in our database
-		a, err := rs.Db.AccountById(id)
+		a, err := rs.Db.AccountById(ctx, id)
 		if err != nil {
-			rs.log.Debugf("GQL->Query->Accounts(): Incoming account '%s' not found.", aid)
+			rs.logger(ctx).Debugf("GQL->Query->Accounts(): Incoming account '%s' not found.", aid)
 			continue
 		}
 
@@ -76,14 +77,14 @@ func (rs *Resolver) Accounts(args *struct{ List *[]graphql.ID }) ([]*types.Accou
 }
 
 // Implements Query.accounts for all the Account in the app
-func (rs *Resolver) AllAccounts() ([]*types.Account, error) {
+func (rs *Resolver) AllAccounts(ctx context.Context) ([]*types.Account, error) {
 	// log the action
-	rs.log.Debugf("GQL->Query->AllAccounts(): List of all accounts is constructed.")
+	rs.logger(ctx).Debugf("GQL->Query->AllAccounts(): List of all accounts is constructed.")
 
 	// get the list of accounts from the Account Service
-	accounts, err := rs.Db.AllAccounts()
+	accounts, err := rs.Db.AllAccounts(ctx)
 	if err != nil {
-		rs.log.Errorf("GQL->Query->AllAccounts(): Can not get list of Accounts. %s", err.Error())
+		rs.logger(ctx).Errorf("GQL->Query->AllAccounts(): Can not get list of Accounts. %s", err.Error())
 		return make([]*types.Account, 0), err
 	}
 
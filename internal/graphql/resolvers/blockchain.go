@@ -1,15 +1,16 @@
 package resolvers
 
 import (
+	"context"
 	"fantomrocks-api/internal/graphql/types"
 	"github.com/graph-gophers/graphql-go"
 )
 
 // Get details of a blockchain Transaction by its identifier / hash
-func (rs *Resolver) BlockchainTransaction(args *struct{ Hash graphql.ID }) (*types.BlockchainTransaction, error) {
-	tx, err := rs.Rpc.TransactionByHash(string(args.Hash))
+func (rs *Resolver) BlockchainTransaction(ctx context.Context, args *struct{ Hash graphql.ID }) (*types.BlockchainTransaction, error) {
+	tx, err := rs.Rpc.TransactionByHash(ctx, string(args.Hash))
 	if err != nil {
-		rs.log.Errorf("GQL->Query->TransactionByHash(): Can not get Transaction. %s", err.Error())
+		rs.logger(ctx).Errorf("GQL->Query->TransactionByHash(): Can not get Transaction. %s", err.Error())
 		return nil, err
 	}
 
@@ -1,17 +1,20 @@
 package resolvers
 
-import "fantomrocks-api/internal/graphql/types"
+import (
+	"context"
+	"fantomrocks-api/internal/graphql/types"
+)
 
 // Implements Query.pair GraphQL entry point for random Account Pair selection
-func (rs *Resolver) Pair() (*types.AccountPair, error) {
+func (rs *Resolver) Pair(ctx context.Context) (*types.AccountPair, error) {
 	// log the action
-	rs.log.Debugf("GQL->Query->Pair(): Random account pair is prepared.")
+	rs.logger(ctx).Debugf("GQL->Query->Pair(): Random account pair is prepared.")
 
 	// get the pair
-	pair, err := rs.Db.RandomPair()
+	pair, err := rs.Db.RandomPair(ctx)
 
 	if err != nil {
-		rs.log.Errorf("GQL->Query->Pair(): Can not get random Account Pair. %s", err.Error())
+		rs.logger(ctx).Errorf("GQL->Query->Pair(): Can not get random Account Pair. %s", err.Error())
 		return &types.AccountPair{}, err
 	}
 
@@ -19,14 +22,14 @@ func (rs *Resolver) Pair() (*types.AccountPair, error) {
 }
 
 // Implements Query.pairs GraphQL entry point
-func (rs *Resolver) Pairs() ([]*types.AccountPair, error) {
+func (rs *Resolver) Pairs(ctx context.Context) ([]*types.AccountPair, error) {
 	// log the action
-	rs.log.Debugf("GQL->Query->Pairs(): List of all account pairs is constructed.")
+	rs.logger(ctx).Debugf("GQL->Query->Pairs(): List of all account pairs is constructed.")
 
 	// get the list of pairs from Account Service
-	pairs, err := rs.Db.AllPairs()
+	pairs, err := rs.Db.AllPairs(ctx)
 	if err != nil {
-		rs.log.Errorf("GQL->Query->AccountPairs(): Can not get list of Account Pairs. %s", err.Error())
+		rs.logger(ctx).Errorf("GQL->Query->AccountPairs(): Can not get list of Account Pairs. %s", err.Error())
 		return make([]*types.AccountPair, 0), err
 	}
 
@@ -0,0 +1,60 @@
+package resolvers
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	"math"
+)
+
+// Define error returned to callers who exceeded their transfers quota.
+type quotaError struct {
+	retryAfter int
+}
+
+// Get the error message.
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("transfers quota exceeded, retry in %ds", e.retryAfter)
+}
+
+// Get GraphQL error extensions with the retry hint.
+func (e *quotaError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": "RATE_LIMITED", "retryAfter": e.retryAfter}
+}
+
+// Define error returned to callers asking for more transfers than their quota allows at once.
+type burstError struct {
+	count int
+}
+
+// Get the error message.
+func (e *burstError) Error() string {
+	return fmt.Sprintf("%d transfers exceed the transfers quota burst, make fewer transfers at once", e.count)
+}
+
+// Get GraphQL error extensions; there is no retry hint, the same request never passes.
+func (e *burstError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": "QUOTA_EXCEEDS_BURST"}
+}
+
+// Make sure the caller can make given number of funds transfers.
+func (rs *Resolver) allowTransfers(ctx context.Context, n int) error {
+	// no quota means no limits
+	q, ok := ctx.Value(common.QuotaContextKey{}).(*services.ClientQuota)
+	if !ok {
+		return nil
+	}
+
+	// check the quota
+	switch err := q.AllowTransfers(n).(type) {
+	case nil:
+		return nil
+	case *services.RateLimitedError:
+		rs.logger(ctx).Warningf("GQL->AllowTransfers(): %d transfers rejected, retry in %s.", n, err.Wait)
+		return &quotaError{retryAfter: int(math.Ceil(err.Wait.Seconds()))}
+	default:
+		rs.logger(ctx).Warningf("GQL->AllowTransfers(): %d transfers rejected. %s", n, err.Error())
+		return &burstError{count: n}
+	}
+}
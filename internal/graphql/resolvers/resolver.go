@@ -1,6 +1,7 @@
 package resolvers
 
 import (
+	"context"
 	"fantomrocks-api/internal/graphql/inputs"
 	"fantomrocks-api/internal/graphql/types"
 	"fantomrocks-api/internal/models"
@@ -12,20 +13,20 @@ import (
 // Define new Use Cases interface
 type UseCases interface {
 	// Query fo Accounts
-	Account(args *struct{ Id *graphql.ID }) (*types.Account, error)
-	RandomAccount() (*types.Account, error)
-	Accounts(*struct{ List *[]graphql.ID }) ([]*types.Account, error)
+	Account(ctx context.Context, args *struct{ Id *graphql.ID }) (*types.Account, error)
+	RandomAccount(context.Context) (*types.Account, error)
+	Accounts(context.Context, *struct{ List *[]graphql.ID }) ([]*types.Account, error)
 
 	// Query for Pairs
-	Pair() (*types.AccountPair, error)
-	Pairs() ([]*types.AccountPair, error)
+	Pair(context.Context) (*types.AccountPair, error)
+	Pairs(context.Context) ([]*types.AccountPair, error)
 
 	// Query for Transactions and Blocks
-	BlockchainTransaction(*struct{ Hash graphql.ID }) (*types.BlockchainTransaction, error)
+	BlockchainTransaction(context.Context, *struct{ Hash graphql.ID }) (*types.BlockchainTransaction, error)
 
 	// Mutation
-	Transfer(*struct{ ToTransfer inputs.TransferInput }) (*types.Transaction, error)
-	Burst(*struct {
+	Transfer(context.Context, *struct{ ToTransfer inputs.TransferInput }) (*types.Transaction, error)
+	Burst(context.Context, *struct {
 		FromAccountId graphql.ID
 		Amount        models.Amount
 		TargetsCount  int32
@@ -42,3 +43,8 @@ type Resolver struct {
 func NewResolver(repo *repository.Repository, log services.Logger) UseCases {
 	return &Resolver{log: log, Repository: repo}
 }
+
+// Get logger tagged with the ID of the request being resolved.
+func (rs *Resolver) logger(ctx context.Context) services.Logger {
+	return services.ContextLogger(ctx, rs.log)
+}
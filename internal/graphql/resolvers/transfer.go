@@ -1,29 +1,36 @@
 package resolvers
 
 import (
+	"context"
 	"fantomrocks-api/internal/graphql/inputs"
 	"fantomrocks-api/internal/graphql/types"
 	"fantomrocks-api/internal/models"
 	"fantomrocks-api/internal/repository/rpc"
+	"fantomrocks-api/internal/services"
 	"github.com/graph-gophers/graphql-go"
 	"strconv"
 )
 
 // Implements Mutation.transfer GraphQL entry point for sending a single transaction between a pair of internal accounts.
-func (rs *Resolver) Transfer(args *struct{ ToTransfer inputs.TransferInput }) (*types.Transaction, error) {
+func (rs *Resolver) Transfer(ctx context.Context, args *struct{ ToTransfer inputs.TransferInput }) (*types.Transaction, error) {
+	// check the caller transfers quota
+	if err := rs.allowTransfers(ctx, 1); err != nil {
+		return nil, err
+	}
+
 	// get the source id
 	fid, err := strconv.Atoi(string(args.ToTransfer.FromAccountId))
 	if err != nil {
 		// log the error and quit
-		rs.log.Errorf("GQL->Mutation->Transfer(): Invalid source account ID [%s]. %s", args.ToTransfer.FromAccountId, err.Error())
+		rs.logger(ctx).Errorf("GQL->Mutation->Transfer(): Invalid source account ID [%s]. %s", args.ToTransfer.FromAccountId, err.Error())
 		return nil, err
 	}
 
 	// get the source
-	from, err := rs.Db.AccountById(fid)
+	from, err := rs.Db.AccountById(ctx, fid)
 	if err != nil {
 		// log the error and quit
-		rs.log.Errorf("GQL->Mutation->Transfer(): Source account not found for account id [%s]. %s", args.ToTransfer.FromAccountId, err.Error())
+		rs.logger(ctx).Errorf("GQL->Mutation->Transfer(): Source account not found for account id [%s]. %s", args.ToTransfer.FromAccountId, err.Error())
 		return nil, err
 	}
 
@@ -31,35 +38,36 @@ func (rs *Resolver) Transfer(args *struct{ ToTransfer inputs.TransferInput }) (*
 	tid, err := strconv.Atoi(string(args.ToTransfer.ToAccountId))
 	if err != nil {
 		// log the error and quit
-		rs.log.Errorf("GQL->Mutation->Transfer(): Invalid destination account ID [%s]. %s", args.ToTransfer.ToAccountId, err.Error())
+		rs.logger(ctx).Errorf("GQL->Mutation->Transfer(): Invalid destination account ID [%s]. %s", args.ToTransfer.ToAccountId, err.Error())
 		return nil, err
 	}
 
 	// get the source
-	to, err := rs.Db.AccountById(tid)
+	to, err := rs.Db.AccountById(ctx, tid)
 	if err != nil {
 		// log the error and quit
-		rs.log.Errorf("GQL->Mutation->Transfer(): Destination account not found for account id [%s]. %s", args.ToTransfer.ToAccountId, err.Error())
+		rs.logger(ctx).Errorf("GQL->Mutation->Transfer(): Destination account not found for account id [%s]. %s", args.ToTransfer.ToAccountId, err.Error())
 		return nil, err
 	}
 
 	// log the action
-	rs.log.Debugf("GQL->Mutation->Transfer(): Sending %s FTM tokens [%s -> %s].", args.ToTransfer.Amount.ToFTM(), from.Name, to.Name)
+	rs.logger(ctx).Debugf("GQL->Mutation->Transfer(): Sending %s FTM tokens [%s -> %s].", args.ToTransfer.Amount.ToFTM(), from.Name, to.Name)
 
-	// do the transfer
-	tr, err := rs.Rpc.TransferTokens(from, to, args.ToTransfer.Amount)
+	// do the transfer; once sent, the client going away must not abort it, the write timeout applies
+	tr, err := rs.Rpc.TransferTokens(services.DetachedContext(ctx), from, to, args.ToTransfer.Amount)
 	if err != nil {
 		// log the action
-		rs.log.Errorf("GQL->Mutation->Transfer(): Can not send tokens. %s", err.Error())
+		rs.logger(ctx).Errorf("GQL->Mutation->Transfer(): Can not send tokens. %s", err.Error())
 		return nil, err
 	}
+	rs.logger(ctx).Infof("GQL->Mutation->Transfer(): Tx %s sent [%s -> %s].", tr.Id, from.Name, to.Name)
 
 	// return nothing
 	return types.NewTransaction(tr, rs.Repository), nil
 }
 
 // Implements Mutation.burst GraphQL entry point for sending blob of transactions to Opera node for parallel processing.
-func (rs *Resolver) Burst(args *struct {
+func (rs *Resolver) Burst(ctx context.Context, args *struct {
 	FromAccountId graphql.ID
 	Amount        models.Amount
 	TargetsCount  int32
@@ -67,66 +75,91 @@ func (rs *Resolver) Burst(args *struct {
 	// prep empty result set slice
 	result := make([]*types.Transaction, 0)
 
+	// check the caller transfers quota; the burst counts as the number of targets requested
+	count := int(args.TargetsCount)
+	if 1 > count {
+		count = 1
+	}
+	if err := rs.allowTransfers(ctx, count); err != nil {
+		return result, err
+	}
+
 	// get the source account id
 	id, err := strconv.Atoi(string(args.FromAccountId))
 	if err != nil {
-		rs.log.Errorf("GQL->Mutation->Burst(): Invalid source account ID [%s]. %s", args.FromAccountId, err.Error())
+		rs.logger(ctx).Errorf("GQL->Mutation->Burst(): Invalid source account ID [%s]. %s", args.FromAccountId, err.Error())
 		return result, err
 	}
 
 	// get the source account details
-	from, err := rs.Db.AccountById(id)
+	from, err := rs.Db.AccountById(ctx, id)
 	if err != nil {
-		rs.log.Errorf("GQL->Mutation->Burst(): Source account not found for account id [%s]. %s", args.FromAccountId, err.Error())
+		rs.logger(ctx).Errorf("GQL->Mutation->Burst(): Source account not found for account id [%s]. %s", args.FromAccountId, err.Error())
 		return nil, err
 	}
 
 	// get list of random account to work with on the burst
-	accounts, err := rs.Db.RandomAccounts(int(args.TargetsCount), []*models.Account{from})
+	accounts, err := rs.Db.RandomAccounts(ctx, int(args.TargetsCount), []*models.Account{from})
 	if err != nil {
 		// log the error and quit
-		rs.log.Errorf("GQL->Mutation->Burst(): Could not get a list of target accounts. %s", err.Error())
+		rs.logger(ctx).Errorf("GQL->Mutation->Burst(): Could not get a list of target accounts. %s", err.Error())
 		return result, err
 	}
 
 	// do we have any accounts to process?
 	if 0 == len(accounts) {
-		rs.log.Errorf("GQL->Mutation->Burst(): Could not continue, requested %d but no accounts found.", args.TargetsCount)
+		rs.logger(ctx).Errorf("GQL->Mutation->Burst(): Could not continue, requested %d but no accounts found.", args.TargetsCount)
 		return result, nil
 	}
 
-	// prep channel to receive transactions
+	// prep channels to receive transactions and errors of the failed ones
 	trs := make(chan *models.Transaction)
+	errs := make(chan error)
 	defer close(trs)
+	defer close(errs)
 
 	// inform
-	rs.log.Debugf("GQL->Mutation->Burst(): Sending %d transactions.", len(accounts))
+	rs.logger(ctx).Debugf("GQL->Mutation->Burst(): Sending %d transactions.", len(accounts))
 
-	// start sending in parallel
+	// start sending in parallel; transfers are not aborted if the client goes away, the write timeout applies
+	wctx := services.DetachedContext(ctx)
 	for _, account := range accounts {
 		// do actual sending
 		go func(acc *models.Account, chain rpc.BlockChain) {
 			// try to push the transfer
-			tr, err := chain.TransferTokens(from, acc, args.Amount)
+			tr, err := chain.TransferTokens(wctx, from, acc, args.Amount)
 			if err != nil {
-				rs.log.Errorf("GQL->Mutation->Burst(): Can not send tokens from %s to %s. %s", from.Name, acc.Name, err.Error())
+				rs.logger(ctx).Errorf("GQL->Mutation->Burst(): Can not send tokens from %s to %s. %s", from.Name, acc.Name, err.Error())
+				errs <- err
+				return
 			}
 
-			// send the transaction to channel (or nil if the transaction failed)
+			// send the transaction to channel
+			rs.logger(ctx).Infof("GQL->Mutation->Burst(): Tx %s sent [%s -> %s].", tr.Id, from.Name, acc.Name)
 			trs <- tr
 		}(account, rs.Repository.Rpc)
 	}
 
-	// we know exactly how many we should get; extract from channel and prep valid TXes for output
+	// we know exactly how many we should get; extract from channels and prep valid TXes for output
+	var failed error
 	for i := 0; i < len(accounts); i++ {
-		tr := <-trs
-		if tr != nil {
+		select {
+		case tr := <-trs:
 			result = append(result, types.NewTransaction(tr, rs.Repository))
+		case err := <-errs:
+			if failed == nil {
+				failed = err
+			}
 		}
 	}
 
 	// inform
-	rs.log.Debugf("GQL->Mutation->Burst(): Done [%d].", len(accounts))
+	rs.logger(ctx).Debugf("GQL->Mutation->Burst(): Done [%d of %d].", len(result), len(accounts))
+
+	// nothing sent at all? the caller gets the reason instead of an empty list
+	if 0 == len(result) && failed != nil {
+		return result, failed
+	}
 
 	// return what we've got here
 	return result, nil
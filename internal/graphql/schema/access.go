@@ -0,0 +1,106 @@
+package gqlschema
+
+import (
+	"fantomrocks-api/internal/models"
+	"fmt"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Define access rules applied to incoming queries before they are executed.
+// Fields restricted by the @hasRole directive require the role anywhere in the query;
+// mutations without the directive are refused so a new mutation is never exposed by mistake.
+type Access struct {
+	roles  map[string]string
+	schema *ast.Schema
+}
+
+// Create new access rules checker from the schema directives.
+func NewAccess() (*Access, error) {
+	sch, err := parsedSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := FieldRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Access{roles: roles, schema: sch}, nil
+}
+
+// Check the caller is allowed to resolve all the fields selected by the parsed query; nil identity is an anonymous caller.
+// Returns list of errors if the access is denied.
+func (a *Access) Check(q *Query, id *models.Identity) gqlerror.List {
+	// no operation; let the executor report the issue
+	op := q.op
+	if op == nil {
+		return nil
+	}
+
+	if err := a.checkSelection(op.SelectionSet, id); err != nil {
+		return gqlerror.List{err}
+	}
+	return nil
+}
+
+// Check access to all the fields of the selection set and their sub-selections.
+func (a *Access) checkSelection(set ast.SelectionSet, id *models.Identity) *gqlerror.Error {
+	for _, sel := range set {
+		var err *gqlerror.Error
+
+		// fragments are transparent
+		switch s := sel.(type) {
+		case *ast.Field:
+			if err = a.checkField(s, id); err == nil {
+				err = a.checkSelection(s.SelectionSet, id)
+			}
+		case *ast.InlineFragment:
+			err = a.checkSelection(s.SelectionSet, id)
+		case *ast.FragmentSpread:
+			err = a.checkSelection(s.Definition.SelectionSet, id)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check access to the field; fields without definition (i.e. __typename) are open.
+func (a *Access) checkField(field *ast.Field, id *models.Identity) *gqlerror.Error {
+	if field.ObjectDefinition == nil || field.Definition == nil {
+		return nil
+	}
+
+	// is the field restricted?
+	name := field.ObjectDefinition.Name + "." + field.Name
+	role, ok := a.roles[name]
+	if !ok {
+		if a.schema.Mutation != nil && field.ObjectDefinition.Name == a.schema.Mutation.Name {
+			return accessError("FORBIDDEN", "access denied; %s is not available", name)
+		}
+		return nil
+	}
+
+	// anonymous callers are not allowed
+	if id == nil {
+		return accessError("UNAUTHENTICATED", "authentication required for %s", name)
+	}
+
+	// does the caller have the role?
+	if !id.HasRole(role) {
+		return accessError("FORBIDDEN", "access denied; %s role required for %s", role, name)
+	}
+	return nil
+}
+
+// Make new error for a field the caller can not access.
+func accessError(code string, format string, args ...interface{}) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message:    fmt.Sprintf(format, args...),
+		Extensions: map[string]interface{}{"code": code},
+	}
+}
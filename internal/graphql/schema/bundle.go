@@ -1,15 +1,7 @@
 package gqlschema
 
-// GraphQL Schema Bundle; auto-created , 2020-02-25 08:22
+// GraphQL Schema Bundle; auto-created , 2026-10-19 09:20
 const schema = `
-# Fantom Account type specification
-type Account {
-    id: ID!
-    name: String!
-    address: String!
-    balance: Amount!
-}
-
 # Transaction inside the chain as a result of Transfer
 type Transaction {
     id: ID!
@@ -19,6 +11,34 @@ type Transaction {
     timeStamp: Time!
 }
 
+# Defines roles of API callers ordered by their privileges
+enum Role {
+    VIEWER
+    OPERATOR
+    ADMIN
+}
+
+# Restricts access to the field for callers with the given role, or a role with higher privileges
+directive @hasRole(role: Role!) on FIELD_DEFINITION
+
+# Raw BlockChain Block details
+type BlockchainBlock {
+    "Unique identifier of the Block."
+    hash: ID!
+
+    "Number of the Block in the chain."
+    number: Number!
+
+    "Timestamp of the Block creation."
+    timeStamp: Time!
+
+    "List of hashes of transaction inside the Block."
+    txHashes: [String!]!
+
+    "List of transactions inside the Block."
+    transactions: [BlockchainTransaction!]! @cost(value: 2, size: 100)
+}
+
 # Holds amount of monetary value
 scalar Amount
 
@@ -28,6 +48,23 @@ scalar Number
 # Holds timestamp
 scalar Time
 
+# Defines input type for Account to Account transfer inside an Account Pair
+input TransferInput {
+    fromAccountId: ID!
+    toAccountId: ID!
+    amount: Amount!
+}
+
+# Sets the cost of resolving the field used to limit complexity of queries;
+# the field costs <size> * (<value> + cost of its sub-selection), where <size> is the expected size of a list
+directive @cost(value: Int!, size: Int = 1) on FIELD_DEFINITION
+
+# Defines pairs of Accounts to be used together
+type AccountPair {
+    one: Account
+    two: Account
+}
+
 # Raw BlockChain Transaction details
 type BlockchainTransaction {
     "Transaction hash identifier."
@@ -64,38 +101,15 @@ type BlockchainTransaction {
     fee: Amount!
 
     "Block the Transaction was in; <null> for pending."
-    block: BlockchainBlock
-}
-
-# Defines pairs of Accounts to be used together
-type AccountPair {
-    one: Account
-    two: Account
+    block: BlockchainBlock @cost(value: 1)
 }
 
-# Defines input type for Account to Account transfer inside an Account Pair
-input TransferInput {
-    fromAccountId: ID!
-    toAccountId: ID!
-    amount: Amount!
-}
-
-# Raw BlockChain Block details
-type BlockchainBlock {
-    "Unique identifier of the Block."
-    hash: ID!
-
-    "Number of the Block in the chain."
-    number: Number!
-
-    "Timestamp of the Block creation."
-    timeStamp: Time!
-
-    "List of hashes of transaction inside the Block."
-    txHashes: [String!]!
-
-    "List of transactions inside the Block."
-    transactions: [BlockchainTransaction!]!
+# Fantom Account type specification
+type Account {
+    id: ID!
+    name: String!
+    address: String!
+    balance: Amount! @cost(value: 1)
 }
 
 # Root schema definition
@@ -107,28 +121,28 @@ schema {
 # Entry points for querying the API
 type Query {
     "Get an Account either specified by ID, or a random one if ID is not provided."
-    account(id:ID):Account!
+    account(id:ID):Account! @cost(value: 1)
 
     "Get list of Accounts specified by their ID."
-    accounts(list:[ID!]):[Account!]!
+    accounts(list:[ID!]):[Account!]! @cost(value: 1, size: 50)
 
     "Get single pair of accounts, either random ar specified by the ID."
-    pair: AccountPair!
+    pair: AccountPair! @cost(value: 1)
 
     "Get account pairs to be used together."
-    pairs: [AccountPair!]!
+    pairs: [AccountPair!]! @cost(value: 1, size: 50)
 
     "Get raw transaction information for given transaction ID."
-    blockchainTransaction(hash:ID!):BlockchainTransaction
+    blockchainTransaction(hash:ID!):BlockchainTransaction @cost(value: 2)
 }
 
 # data mutation entry points
 type Mutation {
     "Transfer funds from one Account to another Account of the same Account Pair."
-    transfer(toTransfer: TransferInput!): Transaction
+    transfer(toTransfer: TransferInput!): Transaction @hasRole(role: OPERATOR) @cost(value: 1)
 
-    "Create a burst of transactions from a single Account to random selection of target accounts."
-    burst(fromAccountId: ID!, amount: Amount!, targetsCount: Int!): [Transaction!]!
+    "Create a burst of transactions from a single Account to random selection of target accounts; fails if none of the transactions could be sent."
+    burst(fromAccountId: ID!, amount: Amount!, targetsCount: Int!): [Transaction!]! @hasRole(role: OPERATOR) @cost(value: 1, size: 50)
 }
 
 `
@@ -0,0 +1,172 @@
+package gqlschema
+
+import (
+	"fmt"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"strconv"
+	"sync"
+)
+
+// Define limits applied to incoming queries before they are executed.
+// Cost of each field is configured in the schema by the @cost directive:
+// a field costs <size> * (<value> + cost of its sub-selection); fields without the directive are free.
+// The limits can be changed while queries are being checked.
+type Limits struct {
+	mu       sync.RWMutex
+	maxDepth int
+	maxCost  int
+	maxBatch int
+}
+
+// Create new query limits checker; zero limit disables the check.
+func NewLimits(maxDepth int, maxCost int, maxBatch int) (*Limits, error) {
+	// the costs are read from the schema, make sure we have it
+	if _, err := parsedSchema(); err != nil {
+		return nil, err
+	}
+
+	l := &Limits{}
+	l.SetLimits(maxDepth, maxCost, maxBatch)
+	return l, nil
+}
+
+// Set the depth and cost of a query and the number of operations in a batch; zero limit disables the check.
+func (l *Limits) SetLimits(maxDepth int, maxCost int, maxBatch int) {
+	l.mu.Lock()
+	l.maxDepth, l.maxCost, l.maxBatch = maxDepth, maxCost, maxBatch
+	l.mu.Unlock()
+}
+
+// Get the current limits.
+func (l *Limits) limits() (maxDepth int, maxCost int, maxBatch int) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.maxDepth, l.maxCost, l.maxBatch
+}
+
+// Check the parsed query against depth and cost limits; returns the cost of the query.
+// Returns list of errors if the query exceeds the limits.
+func (l *Limits) Check(q *Query) (int, gqlerror.List) {
+	// no operation; let the executor report the issue
+	op := q.op
+	if op == nil {
+		return 0, nil
+	}
+	maxDepth, maxCost, _ := l.limits()
+
+	// check the depth
+	if depth := selectionDepth(op.SelectionSet); 0 < maxDepth && depth > maxDepth {
+		return 0, gqlerror.List{limitError("query depth %d exceeds the limit of %d", depth, maxDepth)}
+	}
+
+	// check the cost
+	cost := selectionCost(op.SelectionSet)
+	if 0 < maxCost && cost > maxCost {
+		return cost, gqlerror.List{limitError("query cost %d exceeds the limit of %d", cost, maxCost)}
+	}
+
+	return cost, nil
+}
+
+// Check the number of operations sent in a batch.
+func (l *Limits) CheckBatchSize(size int) gqlerror.List {
+	if _, _, maxBatch := l.limits(); 0 < maxBatch && size > maxBatch {
+		return gqlerror.List{&gqlerror.Error{
+			Message:    fmt.Sprintf("batch of %d operations exceeds the limit of %d", size, maxBatch),
+			Extensions: map[string]interface{}{"code": "BATCH_TOO_LARGE"},
+		}}
+	}
+	return nil
+}
+
+// Check the total cost of a batch of queries; each query of the batch has to be checked on its own too.
+func (l *Limits) CheckBatch(cost int) gqlerror.List {
+	if _, maxCost, _ := l.limits(); 0 < maxCost && cost > maxCost {
+		return gqlerror.List{limitError("batch cost %d exceeds the limit of %d", cost, maxCost)}
+	}
+	return nil
+}
+
+// Make new error for a query over the limits.
+func limitError(format string, args ...interface{}) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message:    fmt.Sprintf(format, args...),
+		Extensions: map[string]interface{}{"code": "QUERY_TOO_COMPLEX"},
+	}
+}
+
+// Calculate the maximal depth of nested fields in the selection set.
+func selectionDepth(set ast.SelectionSet) int {
+	depth := 0
+	for _, sel := range set {
+		var d int
+
+		// fields add a level; fragments are transparent
+		switch s := sel.(type) {
+		case *ast.Field:
+			if isIntrospection(s) {
+				continue
+			}
+			d = 1 + selectionDepth(s.SelectionSet)
+		case *ast.InlineFragment:
+			d = selectionDepth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			d = selectionDepth(s.Definition.SelectionSet)
+		}
+
+		if d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// Calculate the cost of resolving the selection set.
+func selectionCost(set ast.SelectionSet) int {
+	cost := 0
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *ast.Field:
+			if isIntrospection(s) {
+				continue
+			}
+			value, size := fieldCost(s.Definition)
+			cost += size * (value + selectionCost(s.SelectionSet))
+		case *ast.InlineFragment:
+			cost += selectionCost(s.SelectionSet)
+		case *ast.FragmentSpread:
+			cost += selectionCost(s.Definition.SelectionSet)
+		}
+	}
+	return cost
+}
+
+// Check if the field queries the schema itself; tools like GraphiQL send deeply nested
+// introspection queries which are cheap to resolve, so they don't count toward the limits.
+func isIntrospection(field *ast.Field) bool {
+	return "__schema" == field.Name || "__type" == field.Name
+}
+
+// Get the cost and expected list size of the field from its @cost directive.
+func fieldCost(def *ast.FieldDefinition) (value int, size int) {
+	// fields without definition (i.e. __typename) and without cost are free
+	size = 1
+	if def == nil {
+		return 0, size
+	}
+
+	dir := def.Directives.ForName(costDirective)
+	if dir == nil {
+		return 0, size
+	}
+
+	// get the values
+	if arg := dir.Arguments.ForName("value"); arg != nil {
+		value, _ = strconv.Atoi(arg.Value.Raw)
+	}
+	if arg := dir.Arguments.ForName("size"); arg != nil {
+		size, _ = strconv.Atoi(arg.Value.Raw)
+	}
+	return value, size
+}
@@ -0,0 +1,67 @@
+package gqlschema
+
+import (
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"strings"
+	"sync"
+)
+
+// names of directives we process on the schema
+const (
+	hasRoleDirective = "hasRole"
+	costDirective    = "cost"
+)
+
+// the schema parsed for inspection; we parse it only once
+var (
+	parsed      *ast.Schema
+	parsedErr   *gqlerror.Error
+	parsedGuard sync.Once
+)
+
+// Get the schema parsed for inspection of types, fields and directives.
+func parsedSchema() (*ast.Schema, error) {
+	parsedGuard.Do(func() {
+		parsed, parsedErr = gqlparser.LoadSchema(&ast.Source{Name: "schema", Input: schema})
+	})
+
+	// we can not return typed nil error here
+	if parsedErr != nil {
+		return nil, parsedErr
+	}
+	return parsed, nil
+}
+
+// Get map of schema fields restricted by the @hasRole directive.
+// The map is keyed by "Type.field" and holds the lower-case name of the minimal role required.
+func FieldRoles() (map[string]string, error) {
+	// parse the schema so we can inspect fields directives
+	sch, err := parsedSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	// loop all the object types and their fields
+	roles := make(map[string]string)
+	for _, def := range sch.Types {
+		if def.Kind != ast.Object {
+			continue
+		}
+
+		for _, field := range def.Fields {
+			dir := field.Directives.ForName(hasRoleDirective)
+			if dir == nil {
+				continue
+			}
+
+			// get the role required
+			if arg := dir.Arguments.ForName("role"); arg != nil {
+				roles[def.Name+"."+field.Name] = strings.ToLower(arg.Value.Raw)
+			}
+		}
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,54 @@
+package gqlschema
+
+import (
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+)
+
+// Define query parsed and validated against the schema.
+// All the checks before execution share it, so the query is parsed only once before it gets to the executor.
+type Query struct {
+	doc *ast.QueryDocument
+	op  *ast.OperationDefinition
+}
+
+// Parse the query and validate it to get fields bound to their schema definitions.
+// Returns list of errors if the query is invalid.
+func ParseQuery(query string, operationName string) (*Query, gqlerror.List) {
+	sch, err := parsedSchema()
+	if err != nil {
+		return nil, gqlerror.List{gqlerror.Errorf("%s", err.Error())}
+	}
+
+	// parse the query
+	doc, perr := parser.ParseQuery(&ast.Source{Name: "query", Input: query})
+	if perr != nil {
+		return nil, gqlerror.List{perr}
+	}
+
+	// validate it
+	if errs := validator.Validate(sch, doc); errs != nil {
+		return nil, errs
+	}
+
+	return &Query{doc: doc, op: findOperation(doc, operationName)}, nil
+}
+
+// Get type of the operation to be executed by the query (query, mutation or subscription).
+// Returns empty string if the operation can not be found; the executor reports the issue later.
+func (q *Query) OperationType() string {
+	if q.op == nil {
+		return ""
+	}
+	return string(q.op.Operation)
+}
+
+// Find the operation to be executed in the query document; nil if there is no such operation.
+func findOperation(doc *ast.QueryDocument, operationName string) *ast.OperationDefinition {
+	if "" == operationName && 1 == len(doc.Operations) {
+		return doc.Operations[0]
+	}
+	return doc.Operations.ForName(operationName)
+}
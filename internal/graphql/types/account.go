@@ -1,6 +1,8 @@
 package types
 
 import (
+	"context"
+	"fantomrocks-api/internal/graphql/loaders"
 	"fantomrocks-api/internal/models"
 	"fantomrocks-api/internal/repository"
 	"github.com/graph-gophers/graphql-go"
@@ -28,8 +30,9 @@ func (a *Account) ID() graphql.ID {
 }
 
 // Get Account balance for GraphQL.
-func (a *Account) Balance() (models.Amount, error) {
-	balance, err := a.repo.Rpc.AccountBalance(a.acc.Address)
+// Balances of all the accounts resolved within the request are loaded together.
+func (a *Account) Balance(ctx context.Context) (models.Amount, error) {
+	balance, err := loaders.Balance(ctx, a.repo, a.acc.Address)
 	return *balance, err
 }
 
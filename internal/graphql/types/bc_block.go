@@ -1,8 +1,11 @@
 package types
 
 import (
+	"context"
+	"fantomrocks-api/internal/graphql/loaders"
 	"fantomrocks-api/internal/models"
 	"fantomrocks-api/internal/repository"
+	"fantomrocks-api/internal/services"
 	"github.com/graph-gophers/graphql-go"
 )
 
@@ -41,20 +44,21 @@ func (b *BlockchainBlock) TxHashes() []string {
 }
 
 // Resolve full list of Transactions in the Block.
-func (b *BlockchainBlock) Transactions() []*BlockchainTransaction {
+// All the transactions are loaded together in a single batch.
+func (b *BlockchainBlock) Transactions(ctx context.Context) []*BlockchainTransaction {
 	tx := make([]*BlockchainTransaction, 0)
 
 	// any hashes registered in the block?
 	if 0 < len(b.blk.TxHashes) {
-		// loop all hashes and get corresponding transactions
-		for _, h := range b.blk.TxHashes {
-			t, err := b.repo.Rpc.TransactionByHash(h)
-			if err == nil {
+		// load all the transactions and loop them
+		trx, errs := loaders.Transactions(ctx, b.repo, b.blk.TxHashes)
+		for i, t := range trx {
+			if errs[i] == nil {
 				// add the transaction detail to the output
 				tx = append(tx, NewBlockchainTransaction(t, b.repo))
 			} else {
 				// log the error
-				b.repo.Log.Debugf("GQL->BlockchainBlock(): Could not resolve transaction; %s", err.Error())
+				services.ContextLogger(ctx, b.repo.Log).Debugf("GQL->BlockchainBlock(): Could not resolve transaction; %s", errs[i].Error())
 			}
 		}
 	}
@@ -1,8 +1,10 @@
 package types
 
 import (
+	"context"
 	"fantomrocks-api/internal/models"
 	"fantomrocks-api/internal/repository"
+	"fantomrocks-api/internal/services"
 	"github.com/graph-gophers/graphql-go"
 )
 
@@ -76,15 +78,15 @@ func (t *BlockchainTransaction) Fee() models.Amount {
 }
 
 // Resolve the Block this transaction belongs to.
-func (t *BlockchainTransaction) Block() *BlockchainBlock {
+func (t *BlockchainTransaction) Block(ctx context.Context) *BlockchainBlock {
 	// just return no-block
 	if nil == t.tx.BlockHash {
 		return nil
 	}
 
-	b, err := t.repo.Rpc.BlockByHash(*t.tx.BlockHash)
+	b, err := t.repo.Rpc.BlockByHash(ctx, *t.tx.BlockHash)
 	if err != nil {
-		t.repo.Log.Errorf("GQL->BlockchainTransaction():: Block not loaded! %s", err)
+		services.ContextLogger(ctx, t.repo.Log).Errorf("GQL->BlockchainTransaction(): Block not loaded! %s", err.Error())
 		return nil
 	}
 
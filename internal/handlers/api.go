@@ -10,20 +10,68 @@ import (
 	"net/http"
 )
 
-// Construct and return the GraphQL API handler.
-func ApiHandler(cfg *common.Config, repo *repository.Repository, log services.Logger) http.Handler {
+// Construct and return the GraphQL API handler; the caller owns the rate limiter and closes it.
+// CORS origins, rate limits and query limits follow configuration changes announced by the reloader.
+func ApiHandler(cfg *common.Config, repo *repository.Repository, limiter *services.RateLimiter, log services.Logger, reloader *services.ConfigReloader) http.Handler {
 	// we don't want to write a method for each type field if it could be matched directly
-	opts := []graphql.SchemaOpt{graphql.UseFieldResolvers()}
+	// and we want to see resolvers in the traces
+	opts := []graphql.SchemaOpt{graphql.UseFieldResolvers(), graphql.Tracer(graphQLTracer{})}
 
 	// create new parsed GraphQL schema
 	schema := graphql.MustParseSchema(gqlschema.GetSchema(), resolvers.NewResolver(repo, log), opts...)
 
-	// construct handlers chain for the API endpoint
-	return LoggingHandler(log, CORSHandler(log, &CORSOptions{
+	// prep query complexity limits
+	limits, err := gqlschema.NewLimits(cfg.MaxQueryDepth, cfg.MaxQueryCost, cfg.MaxBatchSize)
+	if err != nil {
+		log.Fatalf("ApiHandler(): Can not initialize query limits. %s", err.Error())
+	}
+
+	// prep access rules of schema fields
+	access, err := gqlschema.NewAccess()
+	if err != nil {
+		log.Fatalf("ApiHandler(): Can not initialize access rules. %s", err.Error())
+	}
+
+	// prep persisted queries store
+	persisted, err := NewPersistedQueries(cfg.PersistedCacheSize, cfg.PersistedAllowlist, cfg.PersistedStrict)
+	if err != nil {
+		log.Fatalf("ApiHandler(): Can not initialize persisted queries. %s", err.Error())
+	}
+
+	// create authenticator for API callers
+	auth, err := NewAuthenticator(cfg, repo)
+	if err != nil {
+		log.Fatalf("ApiHandler(): Can not initialize authentication. %s", err.Error())
+	}
+
+	// prep the GraphQL leaf handler
+	gql := GraphQLHandler(log, schema, &GraphQLOptions{
+		Limits:      limits,
+		Access:      access,
+		Persisted:   persisted,
+		CacheMaxAge: cfg.GetCacheMaxAge,
+	})
+
+	// prep CORS options and rate limiter
+	cors := &CORSOptions{
 		AllowOrigins:     cfg.Cors,
 		AllowMethods:     []string{"HEAD", "GET", "POST"},
-		AllowHeaders:     []string{"Origin", "Accept", "Content-Type", "X-Requested-With"},
+		AllowHeaders:     []string{"Origin", "Accept", "Content-Type", "X-Requested-With", "Authorization", "X-Api-Key", "X-Request-ID"},
+		ExposeHeaders:    []string{"X-Request-ID", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           86400,
-	}, GraphQLHandler(log, schema)))
+	}
+	proxies := parseTrustedProxies(cfg.TrustedProxies, log)
+
+	// follow configuration changes
+	reloader.OnReload(func(cfg *common.Config) {
+		cors.SetOrigins(cfg.Cors, log)
+		limiter.SetLimits(cfg)
+		limits.SetLimits(cfg.MaxQueryDepth, cfg.MaxQueryCost, cfg.MaxBatchSize)
+	})
+
+	// construct handlers chain for the API endpoint
+	return LoggingHandler(cfg, log, CORSHandler(log, cors,
+		TracingHandler(cfg.AppName, RateLimitHandler(log, limiter, proxies,
+			AuthHandler(log, auth, QuotaHandler(limiter, proxies, LoadersHandler(cfg, repo, gql)))))))
 }
@@ -0,0 +1,391 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/handlers"
+	"fantomrocks-api/internal/repository"
+	"fantomrocks-api/internal/repository/db"
+	"fantomrocks-api/internal/services"
+	"flag"
+	"github.com/op/go-logging"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// run "go test ./internal/handlers -update" to accept changed responses
+var update = flag.Bool("update", false, "update response snapshots in testdata")
+
+// Define single API call and the expected response status; the response body is compared with its snapshot.
+// The data store is loaded from the fixture in testdata/fixtures, api.yml if not set.
+// If reload is set, the configuration changed by it is reloaded before the call.
+type apiTestCase struct {
+	name      string
+	fixture   string
+	query     string
+	variables map[string]interface{}
+	apiKey    string
+	batch     int
+	status    int
+	configure func(cfg *common.Config)
+	reload    func(cfg *common.Config)
+	store     func(db.DataStore) db.DataStore
+	normalize func(res map[string]interface{})
+}
+
+// GraphQL documents used by the test cases
+const (
+	accountFields = `id name address balance`
+
+	transactionFields = `id amount timeStamp from { id name } to { id name }`
+
+	bcTransactionFields = `hash from to value input nonce txIndex gasLimit gasUsed gasPrice fee
+		block { hash number timeStamp txHashes transactions { hash nonce } }`
+)
+
+var apiTestCases = []apiTestCase{
+	// accounts
+	{name: "account", query: `{ account(id: "2") { ` + accountFields + ` } }`},
+	{name: "account_random", fixture: "single_account.yml", query: `{ account { ` + accountFields + ` } }`},
+	{name: "account_invalid_id", query: `{ account(id: "two") { id } }`},
+	{name: "account_missing", query: `{ account(id: "99") { id } }`},
+	{name: "accounts", query: `{ accounts { ` + accountFields + ` } }`},
+	{name: "accounts_list", query: `{ accounts(list: ["3", "x", "99", "1"]) { ` + accountFields + ` } }`},
+
+	// pairs
+	{name: "pair", fixture: "single_pair.yml", query: `{ pair { one { id name } two { id name } } }`},
+	{name: "pairs", query: `{ pairs { one { ` + accountFields + ` } two { ` + accountFields + ` } } }`},
+
+	// introspection is not limited by the query depth
+	{
+		name:  "introspection_depth",
+		query: `{ __type(name: "AccountPair") { name fields { name type { kind ofType { name } } } } }`,
+		configure: func(cfg *common.Config) {
+			cfg.MaxQueryDepth = 2
+		},
+	},
+
+	// batches
+	{
+		name:  "batch_cost",
+		query: `{ accounts { id } }`,
+		batch: 3,
+		configure: func(cfg *common.Config) {
+			cfg.MaxQueryCost = 120
+		},
+	},
+	{
+		name:   "batch_rate_limited",
+		query:  `{ pair { one { id } } }`,
+		batch:  3,
+		status: http.StatusTooManyRequests,
+		configure: func(cfg *common.Config) {
+			cfg.RateLimitRequests = 0.01
+			cfg.RateLimitRequestsBurst = 2
+		},
+	},
+
+	// reloaded limits
+	{
+		name:  "reload_query_depth",
+		query: `{ pairs { one { id } } }`,
+		reload: func(cfg *common.Config) {
+			cfg.MaxQueryDepth = 2
+		},
+	},
+	{
+		name:  "reload_batch_size",
+		query: `{ pair { one { id } } }`,
+		batch: 3,
+		reload: func(cfg *common.Config) {
+			cfg.MaxBatchSize = 2
+		},
+	},
+
+	// block chain
+	{
+		name:      "blockchain_transaction",
+		query:     `query($hash: ID!) { blockchainTransaction(hash: $hash) { ` + bcTransactionFields + ` } }`,
+		variables: map[string]interface{}{"hash": fakeMinedHash},
+	},
+	{
+		name:      "blockchain_transaction_pending",
+		query:     `query($hash: ID!) { blockchainTransaction(hash: $hash) { ` + bcTransactionFields + ` } }`,
+		variables: map[string]interface{}{"hash": fakePendingHash},
+	},
+	{
+		name:      "blockchain_transaction_missing",
+		query:     `query($hash: ID!) { blockchainTransaction(hash: $hash) { hash } }`,
+		variables: map[string]interface{}{"hash": "0x01"},
+	},
+
+	// transfers
+	{
+		name:   "transfer",
+		query:  `mutation { transfer(toTransfer: {fromAccountId: "1", toAccountId: "2", amount: "1000000000000000000"}) { ` + transactionFields + ` } }`,
+		apiKey: fakeOperatorKey,
+	},
+	{
+		name:  "transfer_anonymous",
+		query: `mutation { transfer(toTransfer: {fromAccountId: "1", toAccountId: "2", amount: "1"}) { id } }`,
+	},
+	{
+		name:   "transfer_viewer",
+		query:  `mutation { transfer(toTransfer: {fromAccountId: "1", toAccountId: "2", amount: "1"}) { id } }`,
+		apiKey: fakeViewerKey,
+	},
+	{
+		name:   "transfer_viewer_fragment",
+		query:  `mutation { ... on Mutation { transfer(toTransfer: {fromAccountId: "1", toAccountId: "2", amount: "1"}) { id } } }`,
+		apiKey: fakeViewerKey,
+	},
+	{
+		name:   "transfer_invalid_key",
+		query:  `mutation { transfer(toTransfer: {fromAccountId: "1", toAccountId: "2", amount: "1"}) { id } }`,
+		apiKey: "unknown-key",
+		status: http.StatusUnauthorized,
+	},
+	{
+		name:   "transfer_key_store_down",
+		query:  `mutation { transfer(toTransfer: {fromAccountId: "1", toAccountId: "2", amount: "1"}) { id } }`,
+		apiKey: fakeOperatorKey,
+		status: http.StatusServiceUnavailable,
+		store:  newBrokenKeyStore,
+	},
+	{
+		name:   "transfer_invalid_id",
+		query:  `mutation { transfer(toTransfer: {fromAccountId: "one", toAccountId: "2", amount: "1"}) { id } }`,
+		apiKey: fakeOperatorKey,
+	},
+	{
+		name:   "transfer_missing_account",
+		query:  `mutation { transfer(toTransfer: {fromAccountId: "1", toAccountId: "99", amount: "1"}) { id } }`,
+		apiKey: fakeOperatorKey,
+	},
+	{
+		name:   "transfer_insufficient_funds",
+		query:  `mutation { transfer(toTransfer: {fromAccountId: "3", toAccountId: "4", amount: "5000000000000000000"}) { id } }`,
+		apiKey: fakeOperatorKey,
+	},
+	{
+		name:   "transfer_rate_limited",
+		query:  `mutation { a: transfer(toTransfer: {fromAccountId: "1", toAccountId: "2", amount: "1"}) { id } b: transfer(toTransfer: {fromAccountId: "1", toAccountId: "3", amount: "1"}) { id } }`,
+		apiKey: fakeOperatorKey,
+		configure: func(cfg *common.Config) {
+			cfg.RateLimitTransfers = 0.01
+			cfg.RateLimitTransfersBurst = 1
+		},
+	},
+
+	// bursts
+	{
+		name:      "burst",
+		query:     `mutation { burst(fromAccountId: "1", amount: "1000", targetsCount: 3) { ` + transactionFields + ` } }`,
+		apiKey:    fakeOperatorKey,
+		normalize: sortBurst,
+	},
+	{
+		name:   "burst_insufficient_funds",
+		query:  `mutation { burst(fromAccountId: "3", amount: "1000000000000000000", targetsCount: 2) { id } }`,
+		apiKey: fakeOperatorKey,
+	},
+	{
+		name:   "burst_exceeds_quota",
+		query:  `mutation { burst(fromAccountId: "1", amount: "1", targetsCount: 3) { id } }`,
+		apiKey: fakeOperatorKey,
+		configure: func(cfg *common.Config) {
+			cfg.RateLimitTransfers = 1
+			cfg.RateLimitTransfersBurst = 2
+		},
+	},
+	{
+		name:   "burst_missing_account",
+		query:  `mutation { burst(fromAccountId: "99", amount: "1", targetsCount: 2) { id } }`,
+		apiKey: fakeOperatorKey,
+	},
+	{
+		name:  "burst_anonymous",
+		query: `mutation { burst(fromAccountId: "1", amount: "1", targetsCount: 2) { id } }`,
+	},
+}
+
+// Silence the logger, the responses tell us everything we need.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	logging.SetBackend(logging.NewLogBackend(ioutil.Discard, "", 0))
+	os.Exit(m.Run())
+}
+
+// Run the API test cases through the full handlers chain against the in-memory store and fake block chain.
+func TestApiHandler(t *testing.T) {
+	for _, tc := range apiTestCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// make a fresh server so rate limits of cases don't interfere
+			h, limiter := newTestHandler(t, &tc)
+			defer limiter.Close()
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+
+			// make the call
+			status, body := callApi(t, srv.URL, &tc)
+			if tc.status == 0 {
+				tc.status = http.StatusOK
+			}
+			if status != tc.status {
+				t.Fatalf("expected status %d, got %d; %s", tc.status, status, body)
+			}
+
+			checkSnapshot(t, tc.name, normalizeResponse(t, body, tc.normalize))
+		})
+	}
+}
+
+// Create the API handler with test configuration, in-memory data store and fake block chain of the test case;
+// the caller closes the rate limiter.
+func newTestHandler(t *testing.T, tc *apiTestCase) (http.Handler, *services.RateLimiter) {
+	fixture := tc.fixture
+	if "" == fixture {
+		fixture = "api.yml"
+	}
+
+	cfg := &common.Config{
+		AppName:                "FantomRocksApiTest",
+		AccessLogLevel:         "NONE",
+		Cors:                   []string{"*"},
+		RpcBatchWait:           time.Millisecond,
+		RpcBatchSize:           100,
+		AuthRolesClaim:         "roles",
+		RateLimitRequests:      100,
+		RateLimitRequestsBurst: 100,
+		MaxQueryDepth:          8,
+		MaxQueryCost:           1000,
+		PersistedCacheSize:     10,
+		MaxBatchSize:           10,
+		DbFixture:              filepath.Join("testdata", "fixtures", fixture),
+	}
+	if tc.configure != nil {
+		tc.configure(cfg)
+	}
+
+	log := logging.MustGetLogger(cfg.AppName)
+	var store db.DataStore
+	store, err := db.NewMemory(cfg, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.store != nil {
+		store = tc.store(store)
+	}
+
+	repo := &repository.Repository{Db: store, Rpc: newFakeChain(), Log: log}
+	// the reloaded configuration is the current one with the changes of the test case
+	reloader := services.NewConfigReloader(cfg, func() (*common.Config, error) {
+		n := *cfg
+		if tc.reload != nil {
+			tc.reload(&n)
+		}
+		return &n, nil
+	}, log)
+
+	limiter := services.NewRateLimiter(cfg)
+	h := handlers.ApiHandler(cfg, repo, limiter, log, reloader)
+	if tc.reload != nil {
+		reloader.Reload()
+	}
+	return h, limiter
+}
+
+// Post the GraphQL request of the test case and get the response status and body.
+// Batch test cases send the query given number of times in a single request.
+func callApi(t *testing.T, url string, tc *apiTestCase) (int, []byte) {
+	var payload interface{} = map[string]interface{}{"query": tc.query, "variables": tc.variables}
+	if 0 < tc.batch {
+		list := make([]interface{}, tc.batch)
+		for i := range list {
+			list[i] = payload
+		}
+		payload = list
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if "" != tc.apiKey {
+		req.Header.Set("X-Api-Key", tc.apiKey)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, body
+}
+
+// Format JSON response for the snapshot; other responses are kept as they are.
+func normalizeResponse(t *testing.T, body []byte, normalize func(map[string]interface{})) []byte {
+	var res map[string]interface{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return body
+	}
+
+	if normalize != nil {
+		normalize(res)
+	}
+
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(out, '\n')
+}
+
+// Compare the response with its snapshot, or write the snapshot if updating.
+func checkSnapshot(t *testing.T, name string, got []byte) {
+	path := filepath.Join("testdata", "api", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("can not read snapshot; %s, run with -update to create it", err.Error())
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("response differs from %s\n--- expected\n%s\n--- got\n%s", path, want, got)
+	}
+}
+
+// Sort transactions of the burst response; the transfers are made in parallel.
+func sortBurst(res map[string]interface{}) {
+	if data, ok := res["data"].(map[string]interface{}); ok {
+		if list, ok := data["burst"].([]interface{}); ok {
+			sortTransactions(list)
+		}
+	}
+}
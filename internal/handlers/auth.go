@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/repository"
+	"fantomrocks-api/internal/repository/db"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"net/http"
+	"strings"
+)
+
+const (
+	authHeaderAuthorization = "Authorization"
+	authHeaderApiKey        = "X-Api-Key"
+	authBearerPrefix        = "bearer "
+	authMethodApiKey        = "key"
+	authMethodJwt           = "jwt"
+)
+
+// Define failure of the authentication back end; the credentials may be valid, we can't tell.
+type authUnavailableError struct {
+	err error
+}
+
+// Get the message of the error; the cause is not disclosed to the caller.
+func (e *authUnavailableError) Error() string {
+	return "authentication not available, try again later"
+}
+
+// Define authenticator of incoming API requests.
+// Callers are identified either by an API key stored in the database,
+// or by a JWT signed by one of the keys from configured JWKS file.
+type Authenticator struct {
+	repo       *repository.Repository
+	jwks       map[string]crypto.PublicKey
+	issuer     string
+	audience   string
+	rolesClaim string
+}
+
+// Create new authenticator for the API requests.
+func NewAuthenticator(cfg *common.Config, repo *repository.Repository) (*Authenticator, error) {
+	// prep the authenticator
+	auth := &Authenticator{
+		repo:       repo,
+		issuer:     cfg.AuthIssuer,
+		audience:   cfg.AuthAudience,
+		rolesClaim: cfg.AuthRolesClaim,
+	}
+
+	// JWT is enabled only if we know the signing keys
+	if "" != cfg.AuthJwksFile {
+		keys, err := loadJwks(cfg.AuthJwksFile)
+		if err != nil {
+			return nil, err
+		}
+		auth.jwks = keys
+	}
+
+	return auth, nil
+}
+
+// Create new authentication middleware HTTP handler.
+// Identified caller is stored in the request context; anonymous requests are passed down the chain untouched.
+func AuthHandler(log services.Logger, auth *Authenticator, h http.Handler) http.Handler {
+	// make new handler using closure
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// try to identify the caller
+		id, err := auth.Identify(r)
+
+		// the back end failed, the caller is not to blame
+		var ue *authUnavailableError
+		if errors.As(err, &ue) {
+			services.ContextLogger(r.Context(), log).Errorf("Auth(): Request from [%s] not authenticated. %s", r.RemoteAddr, ue.err.Error())
+			writeRejected(w, http.StatusServiceUnavailable, err.Error(), map[string]interface{}{"code": "AUTH_UNAVAILABLE"})
+			return
+		}
+		if err != nil {
+			services.ContextLogger(r.Context(), log).Warningf("Auth(): Request from [%s] rejected. %s", r.RemoteAddr, err.Error())
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeRejected(w, http.StatusUnauthorized, err.Error(), map[string]interface{}{"code": "UNAUTHENTICATED"})
+			return
+		}
+
+		// store the identity in the context
+		if id != nil {
+			services.ContextLogger(r.Context(), log).Debugf("Auth(): Request from [%s] identified as %s by %s with roles %v.", r.RemoteAddr, id.Subject, id.Method, id.Roles)
+			r = r.WithContext(context.WithValue(r.Context(), common.IdentityContextKey{}, id))
+		}
+
+		// pass the request down the chain
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Identify the caller of the given request.
+// Returns nil identity and no error for anonymous requests.
+func (a *Authenticator) Identify(r *http.Request) (*models.Identity, error) {
+	// do we have an API key?
+	if key := r.Header.Get(authHeaderApiKey); "" != key {
+		return a.identifyKey(r.Context(), key)
+	}
+
+	// do we have a bearer token?
+	hdr := r.Header.Get(authHeaderAuthorization)
+	if "" == hdr {
+		return nil, nil
+	}
+
+	// only bearer tokens are supported
+	if !strings.HasPrefix(strings.ToLower(hdr), authBearerPrefix) {
+		return nil, fmt.Errorf("unsupported authorization scheme")
+	}
+
+	return a.identifyJwt(strings.TrimSpace(hdr[len(authBearerPrefix):]))
+}
+
+// Identify the caller by an API key.
+func (a *Authenticator) identifyKey(ctx context.Context, key string) (*models.Identity, error) {
+	// we store only hashes of the keys
+	hash := sha256.Sum256([]byte(key))
+
+	// try to find the key
+	ak, err := a.repo.Db.ApiKeyByHash(ctx, hex.EncodeToString(hash[:]))
+	if err == db.ErrApiKeyNotFound {
+		return nil, fmt.Errorf("invalid API key")
+	}
+	if err != nil {
+		return nil, &authUnavailableError{err: err}
+	}
+
+	return &models.Identity{Subject: ak.Name, Method: authMethodApiKey, Roles: splitRoles(ak.Roles)}, nil
+}
+
+// Identify the caller by a signed JWT.
+func (a *Authenticator) identifyJwt(token string) (*models.Identity, error) {
+	// is the JWT authentication enabled?
+	if nil == a.jwks {
+		return nil, fmt.Errorf("JWT authentication not enabled")
+	}
+
+	// parse and verify the token signature and time validity
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, a.signingKey); err != nil {
+		return nil, fmt.Errorf("invalid token; %s", err.Error())
+	}
+
+	// check the issuer
+	if "" != a.issuer && !claims.VerifyIssuer(a.issuer, true) {
+		return nil, fmt.Errorf("invalid token issuer")
+	}
+
+	// check the audience
+	if "" != a.audience && !hasAudience(claims, a.audience) {
+		return nil, fmt.Errorf("invalid token audience")
+	}
+
+	// we need to know who the caller is
+	sub, _ := claims["sub"].(string)
+	if "" == sub {
+		return nil, fmt.Errorf("token subject missing")
+	}
+
+	return &models.Identity{Subject: sub, Method: authMethodJwt, Roles: a.jwtRoles(claims)}, nil
+}
+
+// Find the key the given token has been signed with.
+func (a *Authenticator) signingKey(t *jwt.Token) (interface{}, error) {
+	// only asymmetric signatures are accepted
+	switch t.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+	default:
+		return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+	}
+
+	// find the key by the key id
+	kid, _ := t.Header["kid"].(string)
+	key, ok := a.jwks[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+
+	return key, nil
+}
+
+// Check if the token claims contain the expected audience.
+// The audience can be either a single string, or a list of strings.
+func hasAudience(claims jwt.MapClaims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Extract roles of the caller from the configured token claim.
+// The claim is addressed by a dot separated path, i.e. "realm_access.roles" for KeyCloak realm roles.
+func (a *Authenticator) jwtRoles(claims jwt.MapClaims) []string {
+	// walk the path down to the claim
+	var val interface{} = map[string]interface{}(claims)
+	for _, name := range strings.Split(a.rolesClaim, ".") {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		val = obj[name]
+	}
+
+	// the claim can be either a list, or a string
+	switch roles := val.(type) {
+	case string:
+		return splitRoles(roles)
+	case []interface{}:
+		list := make([]string, 0, len(roles))
+		for _, r := range roles {
+			if s, ok := r.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// Split list of roles separated by commas or spaces.
+func splitRoles(roles string) []string {
+	return strings.FieldsFunc(roles, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -15,6 +16,7 @@ const (
 	corsAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
 	corsAccessControlAllowMethods     = "Access-Control-Allow-Methods"
 	corsAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	corsAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
 	corsAccessControlMaxAge           = "Access-Control-Max-Age"
 	corsHeaderOrigin                  = "Origin"
 	maxHeaderElements                 = 25
@@ -33,6 +35,9 @@ type CORSOptions struct {
 	// A list of non simple headers allowed with cross-domain requests.
 	AllowHeaders []string
 
+	// A list of response headers the client is allowed to read.
+	ExposeHeaders []string
+
 	// Is client allowed to include user credentials like cookies, HTTP authentication or client side SSL certificates?
 	AllowCredentials bool
 
@@ -44,6 +49,9 @@ type CORSOptions struct {
 
 	// Pre-compiled expression for header fields split.
 	splitHeaderRegex *regexp.Regexp
+
+	// Guard of the origins; they can be changed while serving requests.
+	mu sync.RWMutex
 }
 
 // Get default cors options with every origin allowed and basic methods enabled.
@@ -59,11 +67,14 @@ func CORSDefault() *CORSOptions {
 // Get new CORS handler.
 func CORSHandler(log services.Logger, opt *CORSOptions, h http.Handler) http.Handler {
 	// compile wildcard origins
-	opt.wildcardOrigins = compileWildcardOrigins(opt.AllowOrigins, log)
+	opt.SetOrigins(opt.AllowOrigins, log)
 	opt.splitHeaderRegex = regexp.MustCompile(`(,|\s)+`)
 
 	// make new handler using closure
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// log with the request ID
+		log := services.ContextLogger(r.Context(), log)
+
 		// is this an OPTIONS test request?
 		if r.Method == http.MethodOptions && "" != r.Header.Get(corsAccessControlRequestMethod) {
 			// log the action
@@ -85,6 +96,17 @@ func CORSHandler(log services.Logger, opt *CORSOptions, h http.Handler) http.Han
 	})
 }
 
+// Replace the list of allowed origins; requests being served are not affected.
+func (opt *CORSOptions) SetOrigins(origins []string, log services.Logger) {
+	wildcards := compileWildcardOrigins(origins, log)
+
+	opt.mu.Lock()
+	defer opt.mu.Unlock()
+
+	opt.AllowOrigins = origins
+	opt.wildcardOrigins = wildcards
+}
+
 // Compile origins with wildcards to regular expressions we can use to match incoming origins.
 func compileWildcardOrigins(origins []string, log services.Logger) []*regexp.Regexp {
 	// prep container
@@ -221,6 +243,11 @@ func (opt *CORSOptions) handleRequest(w http.ResponseWriter, r *http.Request, lo
 		headers.Set(corsAccessControlAllowCredentials, "true")
 	}
 
+	// indicate which headers the client can read
+	if 0 < len(opt.ExposeHeaders) {
+		headers.Set(corsAccessControlExposeHeaders, strings.Join(opt.ExposeHeaders, ", "))
+	}
+
 	// we are done
 	log.Debugf("Response headers %v.", headers)
 }
@@ -229,6 +256,9 @@ func (opt *CORSOptions) handleRequest(w http.ResponseWriter, r *http.Request, lo
 func (opt *CORSOptions) isOriginAllowed(origin string) bool {
 	origin = strings.ToLower(origin)
 
+	opt.mu.RLock()
+	defer opt.mu.RUnlock()
+
 	// check simple origins
 	for _, o := range opt.AllowOrigins {
 		if o == origin {
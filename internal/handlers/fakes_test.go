@@ -0,0 +1,184 @@
+package handlers_test
+
+import (
+	"context"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/repository/db"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// time stamp of all the fake blocks and transactions
+var fakeTime = time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+// API keys stored by the test fixture
+const (
+	fakeOperatorKey = "operator-key"
+	fakeViewerKey   = "viewer-key"
+)
+
+// Define data store failing to load API keys, as if the database was down.
+type brokenKeyStore struct {
+	db.DataStore
+}
+
+// Wrap the data store so API keys can't be loaded.
+func newBrokenKeyStore(store db.DataStore) db.DataStore {
+	return &brokenKeyStore{DataStore: store}
+}
+
+func (bs *brokenKeyStore) ApiKeyByHash(_ context.Context, _ string) (*models.ApiKey, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+// Define block chain with fixed balances, one block with a transaction and one pending transaction.
+// Transfers are checked against balances, but they don't change them, so the responses are deterministic.
+type fakeChain struct {
+	balances map[string]*big.Int
+	txs      map[string]*models.BcTransaction
+	blocks   map[string]*models.BcBlock
+}
+
+// hashes of the fake blocks and transactions
+const (
+	fakeBlockHash   = "0xb000000000000000000000000000000000000000000000000000000000000001"
+	fakeMinedHash   = "0xa000000000000000000000000000000000000000000000000000000000000001"
+	fakePendingHash = "0xa000000000000000000000000000000000000000000000000000000000000002"
+)
+
+// Create new fake block chain.
+func newFakeChain() *fakeChain {
+	ftm := decimal.New(1, 18)
+	to := "0x2000000000000000000000000000000000000002"
+	block := fakeBlockHash
+	var ix int32
+
+	return &fakeChain{
+		balances: map[string]*big.Int{
+			"0x1000000000000000000000000000000000000001": fakeWei(decimal.New(100, 18)),
+			"0x2000000000000000000000000000000000000002": fakeWei(decimal.New(25, 17)),
+			"0x3000000000000000000000000000000000000003": fakeWei(decimal.New(1, 15)),
+		},
+		txs: map[string]*models.BcTransaction{
+			fakeMinedHash: {
+				Hash:      fakeMinedHash,
+				From:      "0x1000000000000000000000000000000000000001",
+				To:        &to,
+				Value:     models.Amount{Decimal: ftm},
+				Input:     "0x",
+				Nonce:     7,
+				GasLimit:  models.Amount{Decimal: decimal.New(21000, 0)},
+				GasUsed:   models.Amount{Decimal: decimal.New(21000, 0)},
+				GasPrice:  models.Amount{Decimal: decimal.New(1, 9)},
+				Fee:       models.Amount{Decimal: decimal.New(21, 12)},
+				TxIndex:   &ix,
+				BlockHash: &block,
+			},
+			fakePendingHash: {
+				Hash:     fakePendingHash,
+				From:     "0x1000000000000000000000000000000000000001",
+				To:       &to,
+				Value:    models.Amount{Decimal: ftm.Mul(decimal.New(2, 0))},
+				Input:    "0x",
+				Nonce:    8,
+				GasLimit: models.Amount{Decimal: decimal.New(21000, 0)},
+				GasPrice: models.Amount{Decimal: decimal.New(1, 9)},
+			},
+		},
+		blocks: map[string]*models.BcBlock{
+			fakeBlockHash: {
+				Hash:      fakeBlockHash,
+				Number:    models.Number(*(*hexutil.Big)(big.NewInt(1234))),
+				TimeStamp: graphql.Time{Time: fakeTime},
+				TxHashes:  []string{fakeMinedHash},
+			},
+		},
+	}
+}
+
+// Get the amount in WEI as a big integer.
+func fakeWei(val decimal.Decimal) *big.Int {
+	amount := models.Amount{Decimal: val}
+	return amount.ToBig()
+}
+
+func (fc *fakeChain) AccountBalance(_ context.Context, addr string) (*models.Amount, error) {
+	bal, ok := fc.balances[addr]
+	if !ok {
+		return &models.Amount{}, nil
+	}
+	return &models.Amount{Decimal: decimal.NewFromBigInt(bal, 0)}, nil
+}
+
+func (fc *fakeChain) PendingBalance(ctx context.Context, addr string) (*models.Amount, error) {
+	return fc.AccountBalance(ctx, addr)
+}
+
+func (fc *fakeChain) AccountBalances(ctx context.Context, addrs []string) ([]*models.Amount, []error) {
+	res := make([]*models.Amount, len(addrs))
+	errs := make([]error, len(addrs))
+	for i, addr := range addrs {
+		res[i], errs[i] = fc.AccountBalance(ctx, addr)
+	}
+	return res, errs
+}
+
+func (fc *fakeChain) TransactionByHash(_ context.Context, hash string) (*models.BcTransaction, error) {
+	if tx, ok := fc.txs[hash]; ok {
+		return tx, nil
+	}
+	return nil, fmt.Errorf("transaction %s not found", hash)
+}
+
+func (fc *fakeChain) TransactionsByHash(ctx context.Context, hashes []string) ([]*models.BcTransaction, []error) {
+	res := make([]*models.BcTransaction, len(hashes))
+	errs := make([]error, len(hashes))
+	for i, h := range hashes {
+		res[i], errs[i] = fc.TransactionByHash(ctx, h)
+	}
+	return res, errs
+}
+
+func (fc *fakeChain) BlockByHash(_ context.Context, hash string) (*models.BcBlock, error) {
+	if blk, ok := fc.blocks[hash]; ok {
+		return blk, nil
+	}
+	return nil, fmt.Errorf("block %s not found", hash)
+}
+
+func (fc *fakeChain) BlockHeight(_ context.Context) (uint64, error) {
+	return 1234, nil
+}
+
+func (fc *fakeChain) TransferTokens(_ context.Context, from *models.Account, to *models.Account, amount models.Amount) (*models.Transaction, error) {
+	// can the sender pay for it?
+	bal, ok := fc.balances[from.Address]
+	if !ok || bal.Cmp(amount.ToBig()) < 0 {
+		return nil, fmt.Errorf("insufficient funds for gas * price + value")
+	}
+
+	// the hash depends on the accounts only so parallel transfers are predictable
+	return &models.Transaction{
+		Id:          fmt.Sprintf("0xc%063x", from.Id*1000+to.Id),
+		FromAccount: from,
+		ToAccount:   to,
+		Amount:      &amount,
+		TimeStamp:   &graphql.Time{Time: fakeTime},
+	}, nil
+}
+
+func (fc *fakeChain) NewAccount(_ context.Context, _ string) (string, error) {
+	return "", fmt.Errorf("read only chain")
+}
+
+// Sort list of transactions in the response by their ID; burst transfers finish in random order.
+func sortTransactions(list []interface{}) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].(map[string]interface{})["id"].(string) < list[j].(map[string]interface{})["id"].(string)
+	})
+}
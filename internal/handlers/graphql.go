@@ -1,10 +1,21 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fantomrocks-api/internal/common"
+	gqlschema "fantomrocks-api/internal/graphql/schema"
+	"fantomrocks-api/internal/models"
 	"fantomrocks-api/internal/services"
+	"fmt"
 	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 // define GraphQL query parameters structure
@@ -12,36 +23,269 @@ type QueryParams struct {
 	Query         string                 `json:"query"`
 	OperationName string                 `json:"operationName"`
 	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// define GraphQL handler options
+type GraphQLOptions struct {
+	Limits      *gqlschema.Limits
+	Access      *gqlschema.Access
+	Persisted   *PersistedQueries
+	CacheMaxAge time.Duration
+}
+
+// define response to a query rejected before execution
+type queryErrors struct {
+	Errors gqlerror.List `json:"errors"`
 }
 
 // Get new GraphQL HTTP leaf handler.
-func GraphQLHandler(log services.Logger, schema *graphql.Schema) http.Handler {
+// Queries can be sent by GET with parameters in the URL; POST accepts single operation
+// or an array of operations to be executed in a batch.
+func GraphQLHandler(log services.Logger, schema *graphql.Schema, opt *GraphQLOptions) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// try to extract the request details from the HTTP request struct
+		// log with the request ID
+		log := services.ContextLogger(r.Context(), log)
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			serveGet(w, r, log, schema, opt)
+		case http.MethodPost:
+			servePost(w, r, log, schema, opt)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// Serve GraphQL query sent by HTTP GET; mutations are not allowed here.
+func serveGet(w http.ResponseWriter, r *http.Request, log services.Logger, schema *graphql.Schema, opt *GraphQLOptions) {
+	// try to extract the request details from the URL
+	params, err := urlQueryParams(r.URL.Query())
+	if err != nil {
+		log.Errorf("GQL->ServeHTTP(): Request could not be decoded. Probably not a GraphQL request. %s", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// resolve and check the query
+	q, _, errs := prepareQuery(r.Context(), log, params, opt)
+	if errs != nil {
+		recordOperation(r.Context(), params.OperationName, true)
+		writeQueryErrors(w, log, errs)
+		return
+	}
+
+	// only queries can be sent by GET so they can be cached
+	if op := q.OperationType(); "query" != op && "" != op {
+		log.Warningf("GQL->ServeHTTP(): Operation %s rejected on GET request.", op)
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "only queries can be sent by GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// execute the query
+	res := schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables)
+	recordOperation(r.Context(), params.OperationName, 0 < len(res.Errors))
+
+	// successful responses can be cached; responses of identified callers only privately
+	if 0 < opt.CacheMaxAge && 0 == len(res.Errors) {
+		scope := "public"
+		if nil != r.Context().Value(common.IdentityContextKey{}) {
+			scope = "private"
+		}
+		w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, int64(opt.CacheMaxAge/time.Second)))
+	}
+
+	writeResponse(w, log, res)
+}
+
+// Serve single GraphQL operation, or a batch of operations, sent by HTTP POST.
+func servePost(w http.ResponseWriter, r *http.Request, log services.Logger, schema *graphql.Schema, opt *GraphQLOptions) {
+	// read the request body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Errorf("GQL->ServeHTTP(): Request body could not be read. %s", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// single operation?
+	body = bytes.TrimSpace(body)
+	if 0 == len(body) || '[' != body[0] {
 		params := &QueryParams{}
-		if err := json.NewDecoder(r.Body).Decode(params); err != nil {
-			log.Errorf("GQL->ServeHTTP(): Request could not be decoded. Probably not a GraphQL request.", err.Error())
+		if err := json.Unmarshal(body, params); err != nil {
+			log.Errorf("GQL->ServeHTTP(): Request could not be decoded. Probably not a GraphQL request. %s", err.Error())
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// make sure to pass the current common to the resolvers
-		ctx := r.Context()
+		writeResponse(w, log, execute(r, log, schema, opt, params))
+		return
+	}
 
-		// get the response
-		res, err := json.Marshal(schema.Exec(ctx, params.Query, params.OperationName, params.Variables))
-		if err != nil {
-			log.Criticalf("GQL->ServeHTTP(): Response could not be encoded to JSON.", err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	// decode the batch
+	var batch []*QueryParams
+	if err := json.Unmarshal(body, &batch); err != nil || 0 == len(batch) {
+		log.Errorf("GQL->ServeHTTP(): Batch could not be decoded.")
+		http.Error(w, "invalid batch of operations", http.StatusBadRequest)
+		return
+	}
+
+	// check the batch size
+	if errs := opt.Limits.CheckBatchSize(len(batch)); errs != nil {
+		log.Warningf("GQL->ServeHTTP(): Batch of %d operations rejected.", len(batch))
+		writeQueryErrors(w, log, errs)
+		return
+	}
+
+	// each operation counts as a request; the first one was already counted by the rate limiter
+	if q, ok := r.Context().Value(common.RequestQuotaContextKey{}).(*services.ClientQuota); ok {
+		if err := q.AllowRequests(len(batch) - 1); err != nil {
+			log.Warningf("GQL->ServeHTTP(): Batch of %d operations rejected. %s", len(batch), err.Error())
+			writeRateLimited(w, err)
 			return
 		}
+	}
 
-		// write the response
-		w.Header().Set("Content-Type", "application/json")
-		if _, err = w.Write(res); err != nil {
-			log.Errorf("GQL->ServeHTTP(): Can not send response to remote client.", err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	// check all the operations first; the batch as a whole is limited by the query cost too
+	errs := make([]gqlerror.List, len(batch))
+	var total int
+	for i, params := range batch {
+		var cost int
+		_, cost, errs[i] = prepareQuery(r.Context(), log, params, opt)
+		total += cost
+	}
+	if err := opt.Limits.CheckBatch(total); err != nil {
+		log.Warningf("GQL->ServeHTTP(): Batch of %d operations rejected. %s", len(batch), err.Error())
+		writeQueryErrors(w, log, err)
+		return
+	}
+
+	// execute operations in the order they were sent
+	res := make([]interface{}, len(batch))
+	for i, params := range batch {
+		if errs[i] != nil {
+			recordOperation(r.Context(), params.OperationName, true)
+			res[i] = &queryErrors{Errors: errs[i]}
+			continue
 		}
-	})
+		res[i] = run(r, schema, params)
+	}
+
+	writeResponse(w, log, res)
+}
+
+// Execute single operation; returns the executor response, or errors found before execution.
+func execute(r *http.Request, log services.Logger, schema *graphql.Schema, opt *GraphQLOptions, params *QueryParams) interface{} {
+	// resolve and check the query
+	if _, _, errs := prepareQuery(r.Context(), log, params, opt); errs != nil {
+		recordOperation(r.Context(), params.OperationName, true)
+		return &queryErrors{Errors: errs}
+	}
+	return run(r, schema, params)
+}
+
+// Execute operation already checked by prepareQuery.
+func run(r *http.Request, schema *graphql.Schema, params *QueryParams) *graphql.Response {
+	// make sure to pass the current context to the resolvers
+	res := schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables)
+	recordOperation(r.Context(), params.OperationName, 0 < len(res.Errors))
+	return res
+}
+
+// Resolve persisted query, parse it and check the query complexity and the caller access before we execute it.
+// Returns the parsed query and its cost.
+func prepareQuery(ctx context.Context, log services.Logger, params *QueryParams, opt *GraphQLOptions) (*gqlschema.Query, int, gqlerror.List) {
+	// resolve persisted query, if the client sent only the hash
+	query, errs := opt.Persisted.Resolve(params)
+	if errs != nil {
+		log.Debugf("GQL->ServeHTTP(): Persisted query not resolved. %s", errs.Error())
+		return nil, 0, errs
+	}
+	params.Query = query
+
+	// parse and validate the query once for all the checks
+	q, errs := gqlschema.ParseQuery(params.Query, params.OperationName)
+	if errs != nil {
+		log.Warningf("GQL->ServeHTTP(): Query rejected. %s", errs.Error())
+		return nil, 0, errs
+	}
+
+	// check the query complexity
+	cost, errs := opt.Limits.Check(q)
+	if errs != nil {
+		log.Warningf("GQL->ServeHTTP(): Query rejected. %s", errs.Error())
+		return nil, 0, errs
+	}
+
+	// check the caller can access all the fields selected
+	id, _ := ctx.Value(common.IdentityContextKey{}).(*models.Identity)
+	if errs := opt.Access.Check(q, id); errs != nil {
+		log.Warningf("GQL->ServeHTTP(): Access denied. %s", errs.Error())
+		return nil, 0, errs
+	}
+	return q, cost, nil
+}
+
+// Decode query parameters from the URL; variables and extensions are JSON encoded.
+func urlQueryParams(values url.Values) (*QueryParams, error) {
+	params := &QueryParams{
+		Query:         values.Get("query"),
+		OperationName: values.Get("operationName"),
+	}
+
+	// decode variables
+	if v := values.Get("variables"); "" != v {
+		if err := json.Unmarshal([]byte(v), &params.Variables); err != nil {
+			return nil, fmt.Errorf("invalid variables; %s", err.Error())
+		}
+	}
+
+	// decode extensions
+	if v := values.Get("extensions"); "" != v {
+		if err := json.Unmarshal([]byte(v), &params.Extensions); err != nil {
+			return nil, fmt.Errorf("invalid extensions; %s", err.Error())
+		}
+	}
+
+	return params, nil
+}
+
+// Write GraphQL response with the list of errors for a query rejected before execution.
+func writeQueryErrors(w http.ResponseWriter, log services.Logger, errs gqlerror.List) {
+	writeResponse(w, log, &queryErrors{Errors: errs})
+}
+
+// Write GraphQL error response with the given HTTP status for a request rejected before it got to the executor.
+func writeRejected(w http.ResponseWriter, status int, message string, extensions map[string]interface{}) {
+	// prep the response
+	res, err := json.Marshal(&graphql.Response{Errors: []*errors.QueryError{{Message: message, Extensions: extensions}}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// write the response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(res)
+}
+
+// Write JSON encoded GraphQL response.
+func writeResponse(w http.ResponseWriter, log services.Logger, data interface{}) {
+	// encode the response
+	res, err := json.Marshal(data)
+	if err != nil {
+		log.Criticalf("GQL->ServeHTTP(): Response could not be encoded to JSON. %s", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// write the response
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(res); err != nil {
+		log.Errorf("GQL->ServeHTTP(): Can not send response to remote client. %s", err.Error())
+	}
 }
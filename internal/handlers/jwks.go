@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+)
+
+// define JSON Web Key structure as exported by identity providers (i.e. Keycloak)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Load set of public keys used to sign JWT tokens from a JWKS file.
+func loadJwks(path string) (map[string]crypto.PublicKey, error) {
+	// read the file content
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode the key set
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	// convert all the signing keys we understand
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		// skip encryption keys
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		// decode the key
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s; %s", jwk.Kid, err.Error())
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	// do we have anything?
+	if 0 == len(keys) {
+		return nil, fmt.Errorf("no signing keys found in %s", path)
+	}
+
+	return keys, nil
+}
+
+// Decode the public key described by the JSON Web Key.
+func (jwk *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJwkInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeJwkInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+		}
+
+		x, err := decodeJwkInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeJwkInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+}
+
+// Decode base64url encoded big integer value of a JSON Web Key.
+func decodeJwkInt(val string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(val)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/graphql/loaders"
+	"fantomrocks-api/internal/repository"
+	"net/http"
+)
+
+// Create new middleware HTTP handler attaching request scoped data loaders to the request context.
+// The loaders collect data lookups made by resolvers and fetch them in batches.
+func LoadersHandler(cfg *common.Config, repo *repository.Repository, h http.Handler) http.Handler {
+	// make new handler using closure
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// prep fresh loaders for each request
+		ld := loaders.New(r.Context(), repo, cfg.RpcBatchWait, cfg.RpcBatchSize)
+
+		// pass the request down the chain
+		h.ServeHTTP(w, r.WithContext(ld.Attach(r.Context())))
+	})
+}
@@ -1,18 +1,261 @@
 package handlers
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fantomrocks-api/internal/common"
 	"fantomrocks-api/internal/services"
+	"github.com/op/go-logging"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
+const (
+	requestIdHeader     = "X-Request-ID"
+	requestIdMaxLength  = 128
+	forwardedForHeader  = "X-Forwarded-For"
+	accessLogNoneLevel  = "NONE"
+	accessLogNoOpMarker = "-"
+)
+
+// Define access log record of a request; GraphQL handler adds details about executed operations.
+type accessRecord struct {
+	mu         sync.Mutex
+	operations []string
+	errors     bool
+}
+
+// Define response writer wrapper keeping track of the response status and size.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
 // Create new logging middleware HTTP handler.
-func LoggingHandler(log services.Logger, h http.Handler) http.Handler {
+// Each request gets an ID passed down the request context and back to the client in the response header;
+// ID sent by the client, or a proxy in front of us, is used if valid.
+// Served requests are logged to the access log at the configured level.
+func LoggingHandler(cfg *common.Config, log services.Logger, h http.Handler) http.Handler {
+	// prep proxies we trust to tell us the client address
+	proxies := parseTrustedProxies(cfg.TrustedProxies, log)
+
+	// get the access log level
+	access, enabled := accessLogLevel(cfg.AccessLogLevel, log)
+
 	// make new handler using closure
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// get the request ID
+		id := r.Header.Get(requestIdHeader)
+		if !validRequestId(id) {
+			id = newRequestId()
+		}
+
+		// pass the ID to the client and down the chain, prep the access record
+		ar := &accessRecord{}
+		w.Header().Set(requestIdHeader, id)
+		ctx := context.WithValue(r.Context(), common.RequestIdContextKey{}, id)
+		r = r.WithContext(context.WithValue(ctx, common.AccessLogContextKey{}, ar))
+
 		// put out the log
-		log.Debugf("Serving %s %s (%s from [%s]) %s", r.Proto, r.Method, r.UserAgent(), r.RemoteAddr, r.URL)
+		rlog := services.ContextLogger(r.Context(), log)
+		rlog.Debugf("Serving %s %s (%s from [%s]) %s", r.Proto, r.Method, r.UserAgent(), r.RemoteAddr, r.URL)
 
 		// pass the request down the chain
-		h.ServeHTTP(w, r)
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+
+		// write the access log
+		if enabled {
+			logAt(rlog, access, "Access(): client=%s method=%s path=%s status=%d bytes=%d duration=%s operation=%s errors=%t",
+				clientAddress(r, proxies), r.Method, r.URL.Path, rec.status, rec.bytes, time.Since(start), ar.operation(), ar.failed())
+		}
 	})
 }
+
+// Record GraphQL operation executed by the request in the access log record of the context.
+func recordOperation(ctx context.Context, name string, failed bool) {
+	ar, ok := ctx.Value(common.AccessLogContextKey{}).(*accessRecord)
+	if !ok {
+		return
+	}
+
+	// anonymous operations are marked
+	if "" == name {
+		name = accessLogNoOpMarker
+	}
+
+	ar.mu.Lock()
+	ar.operations = append(ar.operations, name)
+	ar.errors = ar.errors || failed
+	ar.mu.Unlock()
+}
+
+// Get names of operations executed by the request.
+func (ar *accessRecord) operation() string {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	if 0 == len(ar.operations) {
+		return accessLogNoOpMarker
+	}
+	return strings.Join(ar.operations, ",")
+}
+
+// Check if any of the operations executed by the request resulted in errors.
+func (ar *accessRecord) failed() bool {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	return ar.errors
+}
+
+// Write the response header and keep the status.
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write the response data and keep track of the size.
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(data)
+	rec.bytes += n
+	return n, err
+}
+
+// Make new random request ID.
+func newRequestId() string {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+// Check if the request ID sent by the client can be used; we accept only printable ASCII without spaces.
+func validRequestId(id string) bool {
+	if "" == id || len(id) > requestIdMaxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Get the access log level; the access log is disabled if the level is empty or NONE.
+func accessLogLevel(level string, log services.Logger) (logging.Level, bool) {
+	if "" == level || strings.EqualFold(level, accessLogNoneLevel) {
+		return logging.INFO, false
+	}
+
+	lvl, err := logging.LogLevel(level)
+	if err != nil {
+		log.Errorf("Invalid access log level '%s', using INFO.", level)
+		return logging.INFO, true
+	}
+	return lvl, true
+}
+
+// Write the log record at the given level.
+func logAt(log services.Logger, level logging.Level, format string, args ...interface{}) {
+	switch level {
+	case logging.CRITICAL:
+		log.Criticalf(format, args...)
+	case logging.ERROR:
+		log.Errorf(format, args...)
+	case logging.WARNING:
+		log.Warningf(format, args...)
+	case logging.NOTICE:
+		log.Noticef(format, args...)
+	case logging.INFO:
+		log.Infof(format, args...)
+	default:
+		log.Debugf(format, args...)
+	}
+}
+
+// Parse list of trusted proxies; both single addresses and CIDR ranges are accepted.
+func parseTrustedProxies(list []string, log services.Logger) []*net.IPNet {
+	// prep container
+	proxies := make([]*net.IPNet, 0, len(list))
+
+	for _, p := range list {
+		// CIDR range
+		if strings.Contains(p, "/") {
+			_, ipNet, err := net.ParseCIDR(p)
+			if err != nil {
+				log.Errorf("Invalid trusted proxy range '%s'. %s", p, err.Error())
+				continue
+			}
+			proxies = append(proxies, ipNet)
+			continue
+		}
+
+		// single address
+		ip := net.ParseIP(p)
+		if ip == nil {
+			log.Errorf("Invalid trusted proxy address '%s'.", p)
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))})
+	}
+
+	return proxies
+}
+
+// Get address of the client; X-Forwarded-For header is honored only if sent by a trusted proxy.
+// The header is walked from the closest hop back and the first untrusted address is the client.
+func clientAddress(r *http.Request, proxies []*net.IPNet) string {
+	// get the remote address
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	// is the remote party a proxy we trust?
+	if !isTrustedProxy(net.ParseIP(host), proxies) {
+		return host
+	}
+
+	// walk the forwarded chain back
+	hops := strings.Split(r.Header.Get(forwardedForHeader), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			break
+		}
+
+		host = hop
+		if !isTrustedProxy(ip, proxies) {
+			break
+		}
+	}
+
+	return host
+}
+
+// Check if the address belongs to a trusted proxy.
+func isTrustedProxy(ip net.IP, proxies []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, p := range proxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
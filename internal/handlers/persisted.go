@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/golang-lru"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"io/ioutil"
+	"strings"
+)
+
+// the only version of the persisted queries protocol we support
+const persistedQueryVersion = 1
+
+// define persisted query extension of a GraphQL request (Apollo automatic persisted queries)
+type persistedQueryExt struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+// Define store of persisted GraphQL queries addressed by their sha256 hash.
+// Clients register queries by sending the query text along with the hash and refer
+// to them by the hash only later. In strict mode only queries from the pre-registered
+// allowlist are accepted and clients can not register new ones.
+type PersistedQueries struct {
+	cache     *lru.Cache
+	allowlist map[string]string
+	strict    bool
+}
+
+// Create new persisted queries store of given size.
+// The allowlist file, if set, contains JSON object mapping sha256 hashes to the queries.
+func NewPersistedQueries(size int, allowlist string, strict bool) (*PersistedQueries, error) {
+	pq := &PersistedQueries{strict: strict}
+
+	// load the allowlist
+	if "" != allowlist {
+		list, err := loadAllowlist(allowlist)
+		if err != nil {
+			return nil, err
+		}
+		pq.allowlist = list
+	}
+
+	// strict mode without allowlist would reject everything
+	if strict && nil == pq.allowlist {
+		return nil, fmt.Errorf("persisted queries strict mode requires an allowlist")
+	}
+
+	// prep the cache of registered queries
+	if 0 < size && !strict {
+		cache, err := lru.New(size)
+		if err != nil {
+			return nil, err
+		}
+		pq.cache = cache
+	}
+
+	return pq, nil
+}
+
+// Resolve the query of the given request parameters.
+// Returns the query to be executed, or errors to be sent back to the client.
+func (pq *PersistedQueries) Resolve(params *QueryParams) (string, gqlerror.List) {
+	// get the persisted query extension, if any
+	ext, err := persistedExtension(params.Extensions)
+	if err != nil {
+		return "", persistedError(err.Error(), "PERSISTED_QUERY_INVALID")
+	}
+
+	// plain query without the extension
+	if nil == ext {
+		if pq.strict && !pq.allowed(queryHash(params.Query)) {
+			return "", persistedError("PersistedQueryNotAllowed", "PERSISTED_QUERY_NOT_ALLOWED")
+		}
+		return params.Query, nil
+	}
+
+	// hash only; do we know the query?
+	hash := strings.ToLower(ext.Sha256Hash)
+	if "" == params.Query {
+		if query, ok := pq.lookup(hash); ok {
+			return query, nil
+		}
+		return "", persistedError("PersistedQueryNotFound", "PERSISTED_QUERY_NOT_FOUND")
+	}
+
+	// the query is being registered; make sure the hash is right
+	if queryHash(params.Query) != hash {
+		return "", persistedError("provided sha does not match query", "PERSISTED_QUERY_HASH_MISMATCH")
+	}
+
+	// only known queries are accepted in strict mode
+	if pq.strict {
+		if !pq.allowed(hash) {
+			return "", persistedError("PersistedQueryNotAllowed", "PERSISTED_QUERY_NOT_ALLOWED")
+		}
+		return params.Query, nil
+	}
+
+	// register the query
+	if nil != pq.cache {
+		pq.cache.Add(hash, params.Query)
+	}
+	return params.Query, nil
+}
+
+// Find the query by its hash in the allowlist and registered queries.
+func (pq *PersistedQueries) lookup(hash string) (string, bool) {
+	// try the allowlist first
+	if query, ok := pq.allowlist[hash]; ok {
+		return query, true
+	}
+
+	// try registered queries
+	if nil != pq.cache {
+		if query, ok := pq.cache.Get(hash); ok {
+			return query.(string), true
+		}
+	}
+	return "", false
+}
+
+// Check if the query hash is on the allowlist.
+func (pq *PersistedQueries) allowed(hash string) bool {
+	_, ok := pq.allowlist[hash]
+	return ok
+}
+
+// Decode the persisted query extension from the request extensions; nil if not present.
+func persistedExtension(ext map[string]interface{}) (*persistedQueryExt, error) {
+	raw, ok := ext["persistedQuery"]
+	if !ok || nil == raw {
+		return nil, nil
+	}
+
+	// re-decode the extension into the structure
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	pqe := &persistedQueryExt{}
+	if err := json.Unmarshal(data, pqe); err != nil {
+		return nil, fmt.Errorf("invalid persisted query extension")
+	}
+
+	// check the version and hash
+	if persistedQueryVersion != pqe.Version {
+		return nil, fmt.Errorf("unsupported persisted query version %d", pqe.Version)
+	}
+	if "" == pqe.Sha256Hash {
+		return nil, fmt.Errorf("persisted query hash missing")
+	}
+	return pqe, nil
+}
+
+// Load the allowlist of queries from the given JSON file.
+// Hashes are verified against the queries so a stale list is detected on start.
+func loadAllowlist(path string) (map[string]string, error) {
+	// read the file content
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode the list
+	var list map[string]string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	// verify the hashes
+	res := make(map[string]string, len(list))
+	for hash, query := range list {
+		hash = strings.ToLower(hash)
+		if queryHash(query) != hash {
+			return nil, fmt.Errorf("allowlist hash %s does not match the query", hash)
+		}
+		res[hash] = query
+	}
+	return res, nil
+}
+
+// Calculate hex encoded sha256 hash of the query.
+func queryHash(query string) string {
+	hash := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(hash[:])
+}
+
+// Make list of errors for a persisted query failure.
+func persistedError(msg string, code string) gqlerror.List {
+	return gqlerror.List{&gqlerror.Error{
+		Message:    msg,
+		Extensions: map[string]interface{}{"code": code},
+	}}
+}
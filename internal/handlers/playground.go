@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	gqlschema "fantomrocks-api/internal/graphql/schema"
+	"fantomrocks-api/internal/services"
+	"html/template"
+	"net/http"
+)
+
+// GraphiQL page template; the page is pointed at the API end point
+var playgroundPage = template.Must(template.New("playground").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>Fantom Rocks API Playground</title>
+    <link rel="stylesheet" href="https://unpkg.com/graphiql@1.0.6/graphiql.min.css">
+    <style>body { margin: 0; height: 100vh; } #graphiql { height: 100vh; }</style>
+</head>
+<body>
+<div id="graphiql">Loading...</div>
+<script src="https://unpkg.com/react@16.14.0/umd/react.production.min.js"></script>
+<script src="https://unpkg.com/react-dom@16.14.0/umd/react-dom.production.min.js"></script>
+<script src="https://unpkg.com/graphiql@1.0.6/graphiql.min.js"></script>
+<script>
+    function fetcher(params) {
+        return fetch({{.Endpoint}}, {
+            method: 'POST',
+            headers: {'Accept': 'application/json', 'Content-Type': 'application/json'},
+            credentials: 'same-origin',
+            body: JSON.stringify(params)
+        }).then(function (res) {
+            return res.json();
+        });
+    }
+    ReactDOM.render(React.createElement(GraphiQL, {fetcher: fetcher}), document.getElementById('graphiql'));
+</script>
+</body>
+</html>
+`))
+
+// Create new HTTP handler serving GraphiQL playground for the API at given end point.
+func PlaygroundHandler(log services.Logger, endpoint string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := playgroundPage.Execute(w, struct{ Endpoint string }{Endpoint: endpoint}); err != nil {
+			log.Errorf("Playground(): Can not send the page to remote client. %s", err.Error())
+		}
+	})
+}
+
+// Create new HTTP handler serving the API schema definition (SDL).
+func SchemaHandler(log services.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := w.Write([]byte(gqlschema.GetSchema())); err != nil {
+			log.Errorf("Schema(): Can not send the schema to remote client. %s", err.Error())
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/services"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// Create new rate limiting middleware HTTP handler.
+// Requests are counted per client address before the caller is authenticated,
+// so invalid credentials can not be used to flood the authentication back-ends.
+// The address is resolved through trusted proxies the same way the access log does.
+func RateLimitHandler(log services.Logger, rl *services.RateLimiter, proxies []*net.IPNet, h http.Handler) http.Handler {
+	// make new handler using closure
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// who is calling
+		client := "ip:" + clientAddress(r, proxies)
+		quota := rl.Quota(client)
+
+		// can the client make the request?
+		if err := quota.AllowRequests(1); err != nil {
+			services.ContextLogger(r.Context(), log).Warningf("RateLimit(): Request from %s rejected. %s", client, err.Error())
+			writeRateLimited(w, err)
+			return
+		}
+
+		// pass the request down the chain; batches take the rest of their requests from the quota
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), common.RequestQuotaContextKey{}, quota)))
+	})
+}
+
+// Create new quota middleware HTTP handler passing the client quota down the chain,
+// so resolvers can apply stricter limits on transfers.
+// Transfers are counted per authenticated caller, or per client address for anonymous callers.
+func QuotaHandler(rl *services.RateLimiter, proxies []*net.IPNet, h http.Handler) http.Handler {
+	// make new handler using closure
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := quotaClient(r, proxies)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), common.QuotaContextKey{}, rl.Quota(client))))
+	})
+}
+
+// Get the quota key of the client making the request.
+func quotaClient(r *http.Request, proxies []*net.IPNet) string {
+	// authenticated callers are limited by their identity
+	if id, ok := r.Context().Value(common.IdentityContextKey{}).(*models.Identity); ok {
+		return id.Method + ":" + id.Subject
+	}
+
+	// anonymous callers are limited by their address
+	return "ip:" + clientAddress(r, proxies)
+}
+
+// Write GraphQL error response for a request rejected by the rate limiter.
+// Only requests which can pass later get the retry hint.
+func writeRateLimited(w http.ResponseWriter, err error) {
+	rle, ok := err.(*services.RateLimitedError)
+	if !ok {
+		writeRejected(w, http.StatusTooManyRequests, err.Error(), map[string]interface{}{"code": "RATE_LIMITED"})
+		return
+	}
+
+	// we advise whole seconds to wait
+	sec := int(math.Ceil(rle.Wait.Seconds()))
+
+	// write the response
+	w.Header().Set("Retry-After", strconv.Itoa(sec))
+	writeRejected(w, http.StatusTooManyRequests, "rate limit exceeded, retry in "+strconv.Itoa(sec)+"s",
+		map[string]interface{}{"code": "RATE_LIMITED", "retryAfter": sec})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/introspection"
+	gqltrace "github.com/graph-gophers/graphql-go/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// Create new tracing middleware HTTP handler.
+// Each request is served in a span; W3C trace context sent by the caller is continued.
+func TracingHandler(name string, h http.Handler) http.Handler {
+	// make new handler using closure
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// continue the trace of the caller, if any
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := services.StartSpan(ctx, fmt.Sprintf("HTTP %s %s", r.Method, r.URL.Path),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(name, r.URL.Path, r)...))
+		defer span.End()
+
+		// link the trace with the request logs
+		if id, ok := r.Context().Value(common.RequestIdContextKey{}).(string); ok {
+			span.SetAttributes(attribute.String("request.id", id))
+		}
+
+		// pass the request down the chain
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r.WithContext(ctx))
+
+		// record the result
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(rec.status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(rec.status))
+	})
+}
+
+// Define GraphQL tracer making spans for executed operations and resolved fields.
+type graphQLTracer struct{}
+
+// Start span of the GraphQL operation.
+func (graphQLTracer) TraceQuery(ctx context.Context, queryString string, operationName string, variables map[string]interface{}, varTypes map[string]*introspection.Type) (context.Context, gqltrace.TraceQueryFinishFunc) {
+	ctx, span := services.StartSpan(ctx, "GraphQL request", trace.WithAttributes(
+		attribute.String("graphql.operation", operationName),
+		attribute.String("graphql.query", queryString)))
+
+	return ctx, func(errs []*errors.QueryError) {
+		if 0 < len(errs) {
+			span.SetStatus(codes.Error, errs[0].Error())
+			span.SetAttributes(attribute.Int("graphql.errors", len(errs)))
+		}
+		span.End()
+	}
+}
+
+// Start span of the resolved field; fields without resolver function are not traced.
+func (graphQLTracer) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]interface{}) (context.Context, gqltrace.TraceFieldFinishFunc) {
+	if trivial {
+		return ctx, func(*errors.QueryError) {}
+	}
+
+	ctx, span := services.StartSpan(ctx, label, trace.WithAttributes(
+		attribute.String("graphql.type", typeName),
+		attribute.String("graphql.field", fieldName)))
+
+	return ctx, func(err *errors.QueryError) {
+		if err != nil {
+			services.SpanError(span, err)
+		}
+		span.End()
+	}
+}
@@ -36,5 +36,15 @@ func (a *Amount) ToFTM() *Amount {
 // Convert Amount to HEX value appropriate for tokens transfer
 // Warning, only integer part of the value is considered! We need to make sure this will work as intended.
 func (a *Amount) ToHex() string {
-	return hexutil.EncodeBig(big.NewInt(a.IntPart()))
+	return hexutil.EncodeBig(a.ToBig())
+}
+
+// Get integer part of the Amount as a big integer; amounts in WEI don't fit int64.
+func (a *Amount) ToBig() *big.Int {
+	i := a.Decimal.Truncate(0)
+	val := i.Coefficient()
+	if exp := i.Exponent(); 0 < exp {
+		val.Mul(val, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+	}
+	return val
 }
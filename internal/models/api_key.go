@@ -0,0 +1,11 @@
+package models
+
+// Define API Key entity.
+type ApiKey struct {
+	Id   int64
+	Name string
+	Hash string `db:"key_hash"`
+
+	// comma separated list of roles assigned to the key
+	Roles string
+}
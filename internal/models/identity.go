@@ -0,0 +1,46 @@
+package models
+
+import "strings"
+
+// Define known roles of API callers.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Roles ordered by their privileges; a role includes all the roles with lower rank.
+var roleRanks = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// Define Identity of an authenticated API caller.
+type Identity struct {
+	// Subject is the API key name, or the subject of the JWT.
+	Subject string
+
+	// Method used to authenticate the caller ("key" or "jwt").
+	Method string
+
+	// Roles assigned to the caller.
+	Roles []string
+}
+
+// Check if the caller has the given role, or a role with higher privileges.
+func (id *Identity) HasRole(role string) bool {
+	// unknown roles can not be granted
+	required, ok := roleRanks[strings.ToLower(role)]
+	if !ok {
+		return false
+	}
+
+	// check all the roles of the caller
+	for _, r := range id.Roles {
+		if rank, ok := roleRanks[strings.ToLower(r)]; ok && rank >= required {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"fantomrocks-api/internal/models"
 	"fmt"
 	"github.com/jmoiron/sqlx"
@@ -17,14 +19,16 @@ const (
 )
 
 // Find account details by the account primary key.
-func (db *DB) AccountById(id int) (*models.Account, error) {
+func (db *DB) AccountById(ctx context.Context, id int) (*models.Account, error) {
 	// make new Account
 	acc := new(models.Account)
 
 	// get the account
-	err := db.Get(acc, sqlAccountById, id)
+	err := db.read(ctx, sqlAccountById, func(ctx context.Context) error {
+		return db.GetContext(ctx, acc, sqlAccountById, id)
+	})
 	if err != nil {
-		db.log.Errorf("DB->AccountById(): Account #%d can not be loaded. %s", id, err.Error())
+		db.logger(ctx).Errorf("DB->AccountById(): Account #%d can not be loaded. %s", id, err.Error())
 		return nil, err
 	}
 
@@ -36,31 +40,35 @@ func (db *DB) AccountById(id int) (*models.Account, error) {
 }
 
 // Get list of all accounts in the local database.
-func (db *DB) AllAccounts() ([]*models.Account, error) {
+func (db *DB) AllAccounts(ctx context.Context) ([]*models.Account, error) {
 	// make the container for results
 	accounts := make([]*models.Account, 0)
 
 	// try to get the data from database
-	err := db.Select(&accounts, sqlAllAccounts)
+	err := db.read(ctx, sqlAllAccounts, func(ctx context.Context) error {
+		return db.SelectContext(ctx, &accounts, sqlAllAccounts)
+	})
 	if err != nil {
-		db.log.Errorf("DB->AllAccounts(): List of accounts can not be loaded. %s", err.Error())
+		db.logger(ctx).Errorf("DB->AllAccounts(): List of accounts can not be loaded. %s", err.Error())
 	}
 
 	return accounts, err
 }
 
 // Get single random account from the database; we don't expect gaps for deleted Accounts.
-func (db *DB) RandomAccount() (*models.Account, error) {
+func (db *DB) RandomAccount(ctx context.Context) (*models.Account, error) {
 	// get how many pairs we have
 	var count int
-	err := db.Get(&count, sqlCountAccounts)
+	err := db.read(ctx, sqlCountAccounts, func(ctx context.Context) error {
+		return db.GetContext(ctx, &count, sqlCountAccounts)
+	})
 	if err != nil {
-		db.log.Errorf("DB->RandomAccount(): Can not count known Accounts. %s", err.Error())
+		db.logger(ctx).Errorf("DB->RandomAccount(): Can not count known Accounts. %s", err.Error())
 		return nil, err
 	}
 
 	// inform
-	db.log.Debugf("DB->RandomAccount(): Found %d Accounts to choose from.", count)
+	db.logger(ctx).Debugf("DB->RandomAccount(): Found %d Accounts to choose from.", count)
 
 	// prep an empty Accounts
 	acc := make([]*models.Account, 0)
@@ -71,9 +79,12 @@ func (db *DB) RandomAccount() (*models.Account, error) {
 		rand.Seed(time.Now().UnixNano())
 
 		// we dont expect gaps so just pull the pair by random id
-		err = db.Select(&acc, sqlAccountById, rand.Intn(count-1)+1)
+		id := rand.Intn(count) + 1
+		err = db.read(ctx, sqlAccountById, func(ctx context.Context) error {
+			return db.SelectContext(ctx, &acc, sqlAccountById, id)
+		})
 		if err != nil {
-			db.log.Errorf("DB->RandomAccount(): Can not get a random Account from database! %s", err.Error())
+			db.logger(ctx).Errorf("DB->RandomAccount(): Can not get a random Account from database! %s", err.Error())
 		}
 	}
 
@@ -88,19 +99,19 @@ func (db *DB) RandomAccount() (*models.Account, error) {
 }
 
 // Get list of <count> or less accounts skipping specified.
-func (db *DB) RandomAccounts(count int, avoid []*models.Account) ([]*models.Account, error) {
+func (db *DB) RandomAccounts(ctx context.Context, count int, avoid []*models.Account) ([]*models.Account, error) {
 	// prep accounts slice
 	accounts := make([]*models.Account, 0)
 
 	// make sure we return at least one element
 	if 1 > count {
-		db.log.Warningf("DB->RandomAccounts(): Expected to return at least one account. %d accounts requested!", count)
+		db.logger(ctx).Warningf("DB->RandomAccounts(): Expected to return at least one account. %d accounts requested!", count)
 		count = 1
 	}
 
 	// limit the top
 	if 50 < count {
-		db.log.Warningf("DB->RandomAccounts(): Too many random accounts (%d) requested!", count)
+		db.logger(ctx).Warningf("DB->RandomAccounts(): Too many random accounts (%d) requested!", count)
 		count = 50
 	}
 
@@ -114,29 +125,35 @@ func (db *DB) RandomAccounts(count int, avoid []*models.Account) ([]*models.Acco
 		}
 
 		// expand binding to cover all the ids expected to be avoided
-		query, args, err := sqlx.In(sqlAllAccountsExcept, ids)
+		var query string
+		var args []interface{}
+		query, args, err = sqlx.In(sqlAllAccountsExcept, ids)
 		if err != nil {
-			db.log.Errorf("DB->RandomAccounts(): Can not expand binding. %s", err.Error())
+			db.logger(ctx).Errorf("DB->RandomAccounts(): Can not expand binding. %s", err.Error())
 			return nil, err
 		}
 
 		// try top extract the data from database
 		query = db.Rebind(query)
-		db.log.Debugf("DB->RandomAccounts(): %s :: %v", query, args)
-		err = db.Select(&accounts, query, args...)
+		db.logger(ctx).Debugf("DB->RandomAccounts(): %s :: %v", query, args)
+		err = db.read(ctx, query, func(ctx context.Context) error {
+			return db.SelectContext(ctx, &accounts, query, args...)
+		})
 	} else {
 		// no limits; get them all
-		err = db.Select(&accounts, sqlAllAccounts)
+		err = db.read(ctx, sqlAllAccounts, func(ctx context.Context) error {
+			return db.SelectContext(ctx, &accounts, sqlAllAccounts)
+		})
 	}
 
 	// did we succeeded with the query?
 	if err != nil {
-		db.log.Errorf("DB->RandomAccounts(): Accounts can not be loaded. %s", err.Error())
+		db.logger(ctx).Errorf("DB->RandomAccounts(): Accounts can not be loaded. %s", err.Error())
 		return nil, err
 	}
 
 	// what we have
-	db.log.Debugf("DB->RandomAccounts(): Found %d accounts.", len(accounts))
+	db.logger(ctx).Debugf("DB->RandomAccounts(): Found %d accounts.", len(accounts))
 
 	// shuffle the slice we've got to extract random accounts
 	rand.Seed(time.Now().UnixNano())
@@ -148,6 +165,45 @@ func (db *DB) RandomAccounts(count int, avoid []*models.Account) ([]*models.Acco
 	}
 
 	// log & return
-	db.log.Debugf("DB->RandomAccounts(): Requested %d, returning %d accounts.", count, len(accounts))
+	db.logger(ctx).Debugf("DB->RandomAccounts(): Requested %d, returning %d accounts.", count, len(accounts))
 	return accounts, nil
 }
+
+// Add new account to the database; accounts with already known address are skipped.
+// Returns true if the account has been added, the ID of the new account is set in that case.
+func (db *DB) AddAccount(ctx context.Context, acc *models.Account) (bool, error) {
+	query := db.dialect.insertAccount
+	err := db.write(ctx, query, func(ctx context.Context) error {
+		// can we get the ID directly?
+		if db.dialect.returningId {
+			return db.QueryRowContext(ctx, query, acc.Name, acc.Address, acc.Password).Scan(&acc.Id)
+		}
+
+		// get the ID of the inserted row, if any
+		res, err := db.ExecContext(ctx, query, acc.Name, acc.Address, acc.Password)
+		if err != nil {
+			return err
+		}
+
+		// nothing is inserted for known address
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if 0 == n {
+			return sql.ErrNoRows
+		}
+		acc.Id, err = res.LastInsertId()
+		return err
+	})
+
+	// no row is returned for known address
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		db.logger(ctx).Errorf("DB->AddAccount(): Account %s can not be added. %s", acc.Address, err.Error())
+		return false, err
+	}
+	return true, nil
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"fantomrocks-api/internal/models"
 	"math/rand"
 	"time"
@@ -15,55 +17,66 @@ const (
 	sqlAccountPairById string = `SELECT one.id as one_id, one.name as one_name, one.address as one_address, two.id as two_id, two.name as two_name, two.address as two_address 
 							FROM account_pair JOIN account one ON one.id = account_pair.account_id_left JOIN account two ON two.id = account_pair.account_id_right
 							WHERE account_pair.id=$1`
+	sqlInsertPair string = `INSERT INTO account_pair (account_id_left, account_id_right) SELECT $1, $2
+							WHERE NOT EXISTS (SELECT 1 FROM account_pair WHERE (account_id_left=$1 AND account_id_right=$2) OR (account_id_left=$2 AND account_id_right=$1))`
 )
 
 // Get list of all account pairs from the database.
-func (db *DB) AllPairs() ([]*models.AccountPair, error) {
+func (db *DB) AllPairs(ctx context.Context) ([]*models.AccountPair, error) {
 	// make the container for results
 	pairs := make([]*models.AccountPair, 0)
 
 	// try to get the data from database
-	rows, err := db.Query(sqlAllPairs)
-	if err != nil {
-		return pairs, err
-	}
+	err := db.read(ctx, sqlAllPairs, func(ctx context.Context) error {
+		// start over if the query is retried
+		pairs = pairs[:0]
 
-	// make sure the cursor is closed when we are done
-	defer rows.Close()
+		rows, err := db.QueryContext(ctx, sqlAllPairs)
+		if err != nil {
+			return err
+		}
 
-	// loop rows
-	for rows.Next() {
-		// prep an empty Accounts
-		one := new(models.Account)
-		two := new(models.Account)
+		// make sure the cursor is closed when we are done
+		defer rows.Close()
 
-		// parse the query row and fill data elements
-		err := rows.Scan(&one.Id, &one.Name, &one.Address, &two.Id, &two.Name, &two.Address)
-		if err != nil {
-			db.log.Errorf("DB->AllPairs(): Pairs row scan error! %s", err.Error())
+		// loop rows
+		for rows.Next() {
+			// prep an empty Accounts
+			one := new(models.Account)
+			two := new(models.Account)
+
+			// parse the query row and fill data elements
+			err := rows.Scan(&one.Id, &one.Name, &one.Address, &two.Id, &two.Name, &two.Address)
+			if err != nil {
+				db.logger(ctx).Errorf("DB->AllPairs(): Pairs row scan error! %s", err.Error())
+			}
+
+			// add new pair into the result set
+			pairs = append(pairs, &models.AccountPair{One: one, Two: two})
 		}
 
-		// add new pair into the result set
-		pairs = append(pairs, &models.AccountPair{One: one, Two: two})
-	}
+		return rows.Err()
+	})
 
-	err = rows.Err()
 	return pairs, err
 }
 
 // Get list of all account pairs from the database.
-func (db *DB) PairById(id int) (*models.AccountPair, error) {
+func (db *DB) PairById(ctx context.Context, id int) (*models.AccountPair, error) {
 	// inform
-	db.log.Debugf("DB->PairById(): Loading Account Pair #%d.", id)
+	db.logger(ctx).Debugf("DB->PairById(): Loading Account Pair #%d.", id)
 
 	// prep an empty Accounts
 	one := new(models.Account)
 	two := new(models.Account)
 
 	// we dont expect gaps so just pull the pair by random id
-	row := db.QueryRow(sqlAccountPairById, id)
-	if err := row.Scan(&one.Id, &one.Name, &one.Address, &two.Id, &two.Name, &two.Address); err != nil {
-		db.log.Errorf("DB->PairById(): Account Pair row scan error! %s", err.Error())
+	err := db.read(ctx, sqlAccountPairById, func(ctx context.Context) error {
+		row := db.QueryRowContext(ctx, sqlAccountPairById, id)
+		return row.Scan(&one.Id, &one.Name, &one.Address, &two.Id, &two.Name, &two.Address)
+	})
+	if err != nil {
+		db.logger(ctx).Errorf("DB->PairById(): Account Pair row scan error! %s", err.Error())
 	}
 
 	// the pair could be send reversed by flip of a coin
@@ -71,17 +84,19 @@ func (db *DB) PairById(id int) (*models.AccountPair, error) {
 }
 
 // Get random account pair from database; we don't expect gaps for deleted Pairs.
-func (db *DB) RandomPair() (*models.AccountPair, error) {
+func (db *DB) RandomPair(ctx context.Context) (*models.AccountPair, error) {
 	// get how many pairs we have
 	var count int
-	err := db.Get(&count, sqlCountPairs)
+	err := db.read(ctx, sqlCountPairs, func(ctx context.Context) error {
+		return db.GetContext(ctx, &count, sqlCountPairs)
+	})
 	if err != nil {
-		db.log.Errorf("DB->RandomPair(): Can not count known pairs. %s", err.Error())
+		db.logger(ctx).Errorf("DB->RandomPair(): Can not count known pairs. %s", err.Error())
 		return nil, err
 	}
 
 	// inform
-	db.log.Debugf("DB->RandomPair(): Found %d Account Pairs to choose from.", count)
+	db.logger(ctx).Debugf("DB->RandomPair(): Found %d Account Pairs to choose from.", count)
 
 	// prep an empty Accounts
 	one := new(models.Account)
@@ -93,12 +108,37 @@ func (db *DB) RandomPair() (*models.AccountPair, error) {
 		rand.Seed(time.Now().UnixNano())
 
 		// we dont expect gaps so just pull the pair by random id
-		row := db.QueryRow(sqlAccountPairById, rand.Intn(count-1)+1)
-		if err := row.Scan(&one.Id, &one.Name, &one.Address, &two.Id, &two.Name, &two.Address); err != nil {
-			db.log.Errorf("DB->RandomPair(): Random Pair row scan error! %s", err.Error())
+		id := rand.Intn(count) + 1
+		err := db.read(ctx, sqlAccountPairById, func(ctx context.Context) error {
+			row := db.QueryRowContext(ctx, sqlAccountPairById, id)
+			return row.Scan(&one.Id, &one.Name, &one.Address, &two.Id, &two.Name, &two.Address)
+		})
+		if err != nil {
+			db.logger(ctx).Errorf("DB->RandomPair(): Random Pair row scan error! %s", err.Error())
 		}
 	}
 
 	// the pair could be send reversed by flip of a coin
 	return &models.AccountPair{One: one, Two: two}, err
 }
+
+// Add new pair of the given accounts; pairs already known in either direction are skipped.
+// Returns true if the pair has been added.
+func (db *DB) AddPair(ctx context.Context, one int64, two int64) (bool, error) {
+	var res sql.Result
+	err := db.write(ctx, sqlInsertPair, func(ctx context.Context) (err error) {
+		res, err = db.ExecContext(ctx, sqlInsertPair, one, two)
+		return err
+	})
+	if err != nil {
+		db.logger(ctx).Errorf("DB->AddPair(): Pair of accounts #%d and #%d can not be added. %s", one, two, err.Error())
+		return false, err
+	}
+
+	// did we insert the row?
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return 0 < n, nil
+}
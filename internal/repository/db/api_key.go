@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fantomrocks-api/internal/models"
+)
+
+// ErrApiKeyNotFound is returned by data stores if there is no active API key of the given hash.
+var ErrApiKeyNotFound = errors.New("API key not found")
+
+// define SQL queries used in service functions
+const (
+	sqlApiKeyByHash string = "SELECT id, name, key_hash, roles FROM api_key WHERE key_hash=$1 AND active"
+)
+
+// Find an active API key by the SHA-256 hash of the key.
+func (db *DB) ApiKeyByHash(ctx context.Context, hash string) (*models.ApiKey, error) {
+	// make new API key
+	key := new(models.ApiKey)
+
+	// get the key
+	err := db.read(ctx, sqlApiKeyByHash, func(ctx context.Context) error {
+		return db.GetContext(ctx, key, sqlApiKeyByHash, hash)
+	})
+
+	// unknown keys are sent by anyone, they are not worth an error record
+	if err == sql.ErrNoRows {
+		db.logger(ctx).Debugf("DB->ApiKeyByHash(): API key not found.")
+		return nil, ErrApiKeyNotFound
+	}
+	if err != nil {
+		db.logger(ctx).Errorf("DB->ApiKeyByHash(): API key can not be loaded. %s", err.Error())
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,199 @@
+package db_test
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/repository/db"
+	"github.com/op/go-logging"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// hash of the API key stored in each data store under test
+const testKeyHash = "a51d4b3fae5a3c53c4e1dc8e4bc78a7e5fb4b0d4c9a5e4b1b5f0a1e2c3d4e5f6"
+
+// Define constructor of an empty data store with the test API key stored;
+// the returned function releases the store.
+type testStoreFactory func(t *testing.T) (db.DataStore, func())
+
+// data stores run through the conformance tests; stores needing build tags register themselves
+var testStores = map[string]testStoreFactory{
+	"memory": newMemoryTestStore,
+}
+
+// Silence the logger, tests report what they need.
+func TestMain(m *testing.M) {
+	logging.SetBackend(logging.NewLogBackend(ioutil.Discard, "", 0))
+	os.Exit(m.Run())
+}
+
+// Create in-memory store; the API key comes from a fixture file.
+func newMemoryTestStore(t *testing.T) (db.DataStore, func()) {
+	dir, err := ioutil.TempDir("", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture := filepath.Join(dir, "fixture.yml")
+	data := "api_keys:\n  - name: conformance\n    key_hash: \"" + testKeyHash + "\"\n    roles: viewer\n"
+	if err := ioutil.WriteFile(fixture, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := db.NewMemory(&common.Config{DbFixture: fixture}, logging.MustGetLogger("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, func() { os.RemoveAll(dir) }
+}
+
+// Run the same checks against all the data stores; they have to behave the same way.
+func TestDataStoreConformance(t *testing.T) {
+	for name, factory := range testStores {
+		factory := factory
+		t.Run(name, func(t *testing.T) {
+			store, done := factory(t)
+			defer done()
+
+			accounts := checkAccounts(t, store)
+			checkPairs(t, store, accounts)
+			checkApiKeys(t, store)
+		})
+	}
+}
+
+// Check accounts are added once per address and found by ID; returns the accounts added.
+func checkAccounts(t *testing.T, store db.DataStore) []*models.Account {
+	ctx := context.Background()
+
+	// add them; names don't follow the insert order so we see the sorting
+	accounts := []*models.Account{
+		{Name: "Dave", Address: "0x4000000000000000000000000000000000000004", Password: "dave-pwd"},
+		{Name: "Alice", Address: "0x1000000000000000000000000000000000000001", Password: "alice-pwd"},
+		{Name: "Carol", Address: "0x3000000000000000000000000000000000000003", Password: "carol-pwd"},
+		{Name: "Bob", Address: "0x2000000000000000000000000000000000000002", Password: "bob-pwd"},
+	}
+	for _, acc := range accounts {
+		ok, err := store.AddAccount(ctx, acc)
+		if err != nil || !ok || 0 >= acc.Id {
+			t.Fatalf("account %s not added; %v, ID %d", acc.Name, err, acc.Id)
+		}
+	}
+
+	// known address is skipped
+	dup := &models.Account{Name: "Dave again", Address: accounts[0].Address, Password: "x"}
+	if ok, err := store.AddAccount(ctx, dup); err != nil || ok {
+		t.Errorf("duplicate address added; %v", err)
+	}
+
+	// find by ID
+	got, err := store.AccountById(ctx, int(accounts[2].Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *accounts[2] {
+		t.Errorf("expected %+v, got %+v", accounts[2], got)
+	}
+	if _, err := store.AccountById(ctx, 9999); err == nil {
+		t.Errorf("expected unknown account error")
+	}
+
+	// all of them ordered by name
+	all, err := store.AllAccounts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(all))
+	for i, acc := range all {
+		names[i] = acc.Name
+	}
+	if len(names) != 4 || names[0] != "Alice" || names[1] != "Bob" || names[2] != "Carol" || names[3] != "Dave" {
+		t.Errorf("unexpected accounts %v", names)
+	}
+
+	// random selection skips accounts to be avoided
+	list, err := store.RandomAccounts(ctx, 10, accounts[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(list))
+	}
+	for _, acc := range list {
+		if acc.Id == accounts[0].Id || acc.Id == accounts[1].Id {
+			t.Errorf("avoided account %s returned", acc.Name)
+		}
+	}
+	if list, err := store.RandomAccounts(ctx, 1, nil); err != nil || len(list) != 1 {
+		t.Errorf("expected single random account, got %d; %v", len(list), err)
+	}
+	if _, err := store.RandomAccount(ctx); err != nil {
+		t.Errorf("expected random account; %s", err.Error())
+	}
+
+	return accounts
+}
+
+// Check pairs are added once in either direction and found by ID.
+func checkPairs(t *testing.T, store db.DataStore, accounts []*models.Account) {
+	ctx := context.Background()
+	dave, alice, carol, bob := accounts[0], accounts[1], accounts[2], accounts[3]
+
+	// the first pair gets ID 1
+	if ok, err := store.AddPair(ctx, alice.Id, bob.Id); err != nil || !ok {
+		t.Fatalf("pair not added; %v", err)
+	}
+
+	// the same pair, in any direction, is not added again;
+	// this relies on $n placeholders used twice in the statement binding right
+	if ok, err := store.AddPair(ctx, alice.Id, bob.Id); err != nil || ok {
+		t.Errorf("duplicate pair added; %v", err)
+	}
+	if ok, err := store.AddPair(ctx, bob.Id, alice.Id); err != nil || ok {
+		t.Errorf("reversed pair added; %v", err)
+	}
+	if ok, err := store.AddPair(ctx, dave.Id, carol.Id); err != nil || !ok {
+		t.Fatalf("second pair not added; %v", err)
+	}
+
+	// all of them ordered by name of the first account
+	all, err := store.AllPairs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].One.Name != "Alice" || all[0].Two.Name != "Bob" || all[1].One.Name != "Dave" || all[1].Two.Address != carol.Address {
+		t.Errorf("unexpected pairs %+v %+v", all[0], all[1])
+	}
+
+	// find by ID; unknown ID gives an empty pair
+	p, err := store.PairById(ctx, 1)
+	if err != nil || p.One.Id != alice.Id || p.Two.Id != bob.Id {
+		t.Errorf("unexpected pair #1 %+v; %v", p, err)
+	}
+	p, err = store.PairById(ctx, 99)
+	if err != nil || p.One.Id != 0 || p.Two.Id != 0 {
+		t.Errorf("expected empty pair, got %+v; %v", p, err)
+	}
+	if p, err := store.RandomPair(ctx); err != nil || 0 == p.One.Id {
+		t.Errorf("expected random pair, got %+v; %v", p, err)
+	}
+}
+
+// Check API keys are found by their hash.
+func checkApiKeys(t *testing.T, store db.DataStore) {
+	ctx := context.Background()
+
+	key, err := store.ApiKeyByHash(ctx, testKeyHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Name != "conformance" || key.Roles != "viewer" || key.Hash != testKeyHash {
+		t.Errorf("unexpected API key %+v", key)
+	}
+	if _, err := store.ApiKeyByHash(ctx, "unknown"); err != db.ErrApiKeyNotFound {
+		t.Errorf("expected unknown API key error, got %v", err)
+	}
+}
@@ -1,51 +1,113 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"fantomrocks-api/internal/common"
 	"fantomrocks-api/internal/models"
 	"fantomrocks-api/internal/services"
 	"fmt"
-	_ "github.com/jackc/pgx/stdlib"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"time"
 )
 
 // DataStore interface definition.
 type DataStore interface {
 	// accounts related
-	AccountById(int) (*models.Account, error)
-	AllAccounts() ([]*models.Account, error)
-	RandomAccount() (*models.Account, error)
-	RandomAccounts(count int, avoid []*models.Account) ([]*models.Account, error)
+	AccountById(context.Context, int) (*models.Account, error)
+	AllAccounts(context.Context) ([]*models.Account, error)
+	RandomAccount(context.Context) (*models.Account, error)
+	RandomAccounts(ctx context.Context, count int, avoid []*models.Account) ([]*models.Account, error)
+	AddAccount(context.Context, *models.Account) (bool, error)
 
 	// pairs related
-	AllPairs() ([]*models.AccountPair, error)
-	PairById(int) (*models.AccountPair, error)
-	RandomPair() (*models.AccountPair, error)
+	AllPairs(context.Context) ([]*models.AccountPair, error)
+	PairById(context.Context, int) (*models.AccountPair, error)
+	RandomPair(context.Context) (*models.AccountPair, error)
+	AddPair(ctx context.Context, one int64, two int64) (bool, error)
+
+	// authentication related
+	ApiKeyByHash(context.Context, string) (*models.ApiKey, error)
 }
 
 // Database adapter
 type DB struct {
 	log services.Logger
 	*sqlx.DB
+
+	// SQL dialect of the database
+	dialect *dialect
+
+	// queries timeout and retry policy
+	timeout time.Duration
+	retries int
+	backoff time.Duration
 }
 
 // Get active adapter to a database holding additional data we need to serve the API.
+// The database schema has to be migrated to the latest version known; the in-memory store
+// is used for the "memory" driver.
 func NewDB(cfg *common.Config, log services.Logger) (DataStore, error) {
+	// in-memory store has no schema to check
+	if memoryDriver == cfg.DbDriver {
+		return NewMemory(cfg, log)
+	}
+
+	db, err := OpenDB(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the schema version
+	version, err := db.SchemaVersion(context.Background())
+	if err != nil {
+		log.Criticalf("NewDB(): Can not check database schema version! %s", err.Error())
+		return nil, err
+	}
+	if version != LatestSchemaVersion() {
+		err = fmt.Errorf("database schema version %d, expected %d", version, LatestSchemaVersion())
+		if version < LatestSchemaVersion() {
+			err = fmt.Errorf("%s; run migrate command to upgrade the database", err.Error())
+		}
+		log.Criticalf("NewDB(): Unknown database schema! %s", err.Error())
+		return nil, err
+	}
+	return db, nil
+}
+
+// Open connection to the database without checking the schema; used to maintain the database.
+func OpenDB(cfg *common.Config, log services.Logger) (*DB, error) {
+	// only SQL databases can be opened
+	dia, ok := dialects[cfg.DbDriver]
+	if !ok {
+		return nil, fmt.Errorf("driver %s is not an SQL database", cfg.DbDriver)
+	}
+
+	// is the driver built in?
+	if !isDriverRegistered(dia.driver) {
+		err := fmt.Errorf("%s driver is not built in; build with \"-tags %s\"", dia.name, dia.name)
+		log.Criticalf("OpenDB(): Fatal error, can not initialize DB layer! %s", err.Error())
+		return nil, err
+	}
+
 	// log actions
-	log.Debugf("NewDB(): Connecting database server [%s:%s/%s]", cfg.DbHost, cfg.DbPort, cfg.DbName)
+	log.Debugf("OpenDB(): Connecting %s database server [%s:%s/%s]", dia.name, cfg.DbHost, cfg.DbPort, cfg.DbName)
 
 	// try to open the connection
-	db, err := sqlx.Open("pgx", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", cfg.DbHost, cfg.DbPort, cfg.DbUser, cfg.DbPassword, cfg.DbName))
+	db, err := sqlx.Open(dia.driver, dia.source(cfg))
 
 	// do we have an error on obtaining the connection
 	if err != nil {
-		log.Criticalf("NewDB(): Fatal error, can not initialize DB layer! %s", err.Error())
+		log.Criticalf("OpenDB(): Fatal error, can not initialize DB layer! %s", err.Error())
 		return nil, err
 	}
 
 	// try if the server is life
 	if err = db.Ping(); err != nil {
-		log.Criticalf("NewDB(): Fatal error, DB connection can not be established! %s", err.Error())
+		log.Criticalf("OpenDB(): Fatal error, DB connection can not be established! %s", err.Error())
 		return nil, err
 	}
 
@@ -53,6 +115,79 @@ func NewDB(cfg *common.Config, log services.Logger) (DataStore, error) {
 	db.SetMaxOpenConns(cfg.DbMaxOpenConnections)
 
 	// success
-	log.Debugf("NewDB(): Database adapter ready.")
-	return &DB{log: log, DB: db}, nil
+	log.Debugf("OpenDB(): Database adapter ready.")
+	return &DB{log: log, DB: db, dialect: dia, timeout: cfg.DbTimeout, retries: cfg.DbRetries, backoff: cfg.DbRetryBackoff}, nil
+}
+
+// Check if the database/sql driver of the given name is registered; optional drivers depend on build tags.
+func isDriverRegistered(name string) bool {
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute idempotent read of the statement with the query timeout applied.
+// Failed reads are retried with backoff doubling on each attempt, unless the caller gives up.
+func (db *DB) read(ctx context.Context, statement string, query func(context.Context) error) error {
+	// trace the query
+	ctx, span := services.StartSpan(ctx, "DB.Query", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(db.dialect.system, semconv.DBStatementKey.String(statement)))
+	defer span.End()
+
+	backoff := db.backoff
+	for attempt := 0; ; attempt++ {
+		// try the query
+		err := db.try(ctx, query)
+
+		// missing data and cancelled requests are not worth retrying
+		if err == nil || err == sql.ErrNoRows || ctx.Err() != nil || attempt >= db.retries {
+			if err != nil && err != sql.ErrNoRows {
+				services.SpanError(span, err)
+			}
+			return err
+		}
+
+		// wait before the next attempt
+		db.logger(ctx).Warningf("DB->Read(): Query failed, retrying in %s. %s", backoff, err.Error())
+		span.AddEvent("retry", trace.WithAttributes(attribute.String("error", err.Error())))
+		select {
+		case <-ctx.Done():
+			services.SpanError(span, ctx.Err())
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// Execute the statement modifying data with the query timeout applied; writes are never retried.
+func (db *DB) write(ctx context.Context, statement string, exec func(context.Context) error) error {
+	// trace the statement
+	ctx, span := services.StartSpan(ctx, "DB.Exec", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(db.dialect.system, semconv.DBStatementKey.String(statement)))
+	defer span.End()
+
+	err := db.try(ctx, exec)
+	if err != nil && err != sql.ErrNoRows {
+		services.SpanError(span, err)
+	}
+	return err
+}
+
+// Execute the query with the query timeout applied.
+func (db *DB) try(ctx context.Context, query func(context.Context) error) error {
+	if 0 < db.timeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, db.timeout)
+		defer cancel()
+	}
+	return query(ctx)
+}
+
+// Get logger tagged with the ID of the request the query belongs to.
+func (db *DB) logger(ctx context.Context) services.Logger {
+	return services.ContextLogger(ctx, db.log)
 }
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"fantomrocks-api/internal/common"
+	"fmt"
+	_ "github.com/jackc/pgx/stdlib"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// names of the supported SQL database drivers
+const (
+	postgresDriver = "postgres"
+	sqliteDriver   = "sqlite"
+)
+
+// Define SQL dialect of a database; only statements differing between the databases are listed here.
+// Other statements use $n placeholders, SQLite binds them in the order of their first use.
+type dialect struct {
+	// name of the dialect; migrations are kept per dialect name
+	name string
+
+	// name of the database/sql driver and the data source of the configuration
+	driver string
+	source func(cfg *common.Config) string
+
+	// database system reported in traces
+	system attribute.KeyValue
+
+	// statements
+	insertAccount          string
+	schemaMigrationsExist  string
+	createSchemaMigrations string
+
+	// does the insertAccount statement return ID of the new account?
+	returningId bool
+}
+
+// supported SQL dialects by the configured driver name
+var dialects = map[string]*dialect{
+	postgresDriver: {
+		name:   postgresDriver,
+		driver: "pgx",
+		source: func(cfg *common.Config) string {
+			return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", cfg.DbHost, cfg.DbPort, cfg.DbUser, cfg.DbPassword, cfg.DbName)
+		},
+		system:                semconv.DBSystemPostgreSQL,
+		insertAccount:         "INSERT INTO account (name, address, pwd) VALUES ($1, $2, $3) ON CONFLICT (address) DO NOTHING RETURNING id",
+		schemaMigrationsExist: "SELECT count(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'",
+		createSchemaMigrations: `CREATE TABLE IF NOT EXISTS schema_migrations (
+				version bigint NOT NULL,
+				name varchar(100) NOT NULL,
+				applied_at timestamp with time zone NOT NULL DEFAULT now(),
+				CONSTRAINT "PK_schema_migrations" PRIMARY KEY (version))`,
+		returningId: true,
+	},
+	sqliteDriver: {
+		name:   sqliteDriver,
+		driver: "sqlite3",
+		source: func(cfg *common.Config) string {
+			return fmt.Sprintf("file:%s?_foreign_keys=1&_busy_timeout=5000&_journal_mode=WAL", cfg.DbName)
+		},
+		system:                semconv.DBSystemSqlite,
+		insertAccount:         "INSERT INTO account (name, address, pwd) VALUES ($1, $2, $3) ON CONFLICT (address) DO NOTHING",
+		schemaMigrationsExist: "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'",
+		createSchemaMigrations: `CREATE TABLE IF NOT EXISTS schema_migrations (
+				version integer NOT NULL PRIMARY KEY,
+				name varchar(100) NOT NULL,
+				applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP)`,
+	},
+}
@@ -0,0 +1,369 @@
+package db
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// name of the driver selecting in-memory data store
+const memoryDriver = "memory"
+
+// In-memory data store adapter for tests and demos; data are lost when the server stops.
+type Memory struct {
+	log services.Logger
+	mu  sync.RWMutex
+	rnd *rand.Rand
+
+	// stored entities
+	accounts []*models.Account
+	pairs    []*memoryPair
+	apiKeys  []*memoryApiKey
+
+	// last used IDs
+	lastAccountId int64
+	lastPairId    int64
+}
+
+// Define stored pair of accounts.
+type memoryPair struct {
+	Id  int64 `yaml:"id"`
+	One int64 `yaml:"one"`
+	Two int64 `yaml:"two"`
+}
+
+// Define stored API key.
+type memoryApiKey struct {
+	Id     int64  `yaml:"id"`
+	Name   string `yaml:"name"`
+	Hash   string `yaml:"key_hash"`
+	Roles  string `yaml:"roles"`
+	Active *bool  `yaml:"active"`
+}
+
+// Define content of a fixture file the memory store can be loaded from.
+type memoryFixture struct {
+	Accounts []struct {
+		Id       int64  `yaml:"id"`
+		Name     string `yaml:"name"`
+		Address  string `yaml:"address"`
+		Password string `yaml:"password"`
+	} `yaml:"accounts"`
+	Pairs   []*memoryPair   `yaml:"pairs"`
+	ApiKeys []*memoryApiKey `yaml:"api_keys"`
+}
+
+// Create new in-memory data store, loaded from the fixture file set by the configuration, if any.
+func NewMemory(cfg *common.Config, log services.Logger) (*Memory, error) {
+	m := &Memory{log: log, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+	// load the fixture
+	if "" != cfg.DbFixture {
+		if err := m.load(cfg.DbFixture); err != nil {
+			log.Criticalf("NewMemory(): Can not load fixture %s! %s", cfg.DbFixture, err.Error())
+			return nil, err
+		}
+	}
+
+	log.Debugf("NewMemory(): In-memory data store ready with %d accounts and %d pairs.", len(m.accounts), len(m.pairs))
+	return m, nil
+}
+
+// Load entities from YAML, or JSON, fixture file.
+func (m *Memory) load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var fx memoryFixture
+	if err := yaml.UnmarshalStrict(data, &fx); err != nil {
+		return err
+	}
+
+	// accounts first, pairs refer to them
+	for _, a := range fx.Accounts {
+		if err := m.insertAccount(&models.Account{Id: a.Id, Name: a.Name, Address: a.Address, Password: a.Password}); err != nil {
+			return err
+		}
+	}
+	for _, p := range fx.Pairs {
+		if err := m.insertPair(p); err != nil {
+			return err
+		}
+	}
+
+	// API keys; keys are active unless said otherwise
+	for i, k := range fx.ApiKeys {
+		if 0 == k.Id {
+			k.Id = int64(i + 1)
+		}
+		if "" == k.Roles {
+			k.Roles = "operator"
+		}
+		if m.apiKeyByHash(k.Hash) != nil {
+			return fmt.Errorf("duplicate API key hash %s", k.Hash)
+		}
+		m.apiKeys = append(m.apiKeys, k)
+	}
+	return nil
+}
+
+// Find account details by the account primary key.
+func (m *Memory) AccountById(ctx context.Context, id int) (*models.Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	acc := m.accountById(int64(id))
+	if acc == nil {
+		return nil, fmt.Errorf("Memory->AccountById(): Account #%d not found", id)
+	}
+	return copyAccount(acc), nil
+}
+
+// Get list of all accounts ordered by name.
+func (m *Memory) AllAccounts(ctx context.Context) ([]*models.Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sortedAccounts(nil), nil
+}
+
+// Get single random account.
+func (m *Memory) RandomAccount(ctx context.Context) (*models.Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if 0 == len(m.accounts) {
+		return nil, fmt.Errorf("random account not found")
+	}
+	return copyAccount(m.accounts[m.rnd.Intn(len(m.accounts))]), nil
+}
+
+// Get list of <count> or less accounts skipping specified; at least one and at most 50 accounts are returned.
+func (m *Memory) RandomAccounts(ctx context.Context, count int, avoid []*models.Account) ([]*models.Account, error) {
+	// make sure we return at least one element
+	if 1 > count {
+		services.ContextLogger(ctx, m.log).Warningf("Memory->RandomAccounts(): Expected to return at least one account. %d accounts requested!", count)
+		count = 1
+	}
+
+	// limit the top
+	if 50 < count {
+		services.ContextLogger(ctx, m.log).Warningf("Memory->RandomAccounts(): Too many random accounts (%d) requested!", count)
+		count = 50
+	}
+
+	// accounts to be avoided
+	skip := make(map[int64]bool, len(avoid))
+	for _, acc := range avoid {
+		skip[acc.Id] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// shuffle what's left and take the requested number
+	accounts := m.sortedAccounts(skip)
+	m.rnd.Shuffle(len(accounts), func(i, j int) { accounts[i], accounts[j] = accounts[j], accounts[i] })
+	if count < len(accounts) {
+		accounts = accounts[:count]
+	}
+	return accounts, nil
+}
+
+// Add new account; accounts with already known address are skipped.
+// Returns true if the account has been added, the ID of the new account is set in that case.
+func (m *Memory) AddAccount(ctx context.Context, acc *models.Account) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.accountByAddress(acc.Address) != nil {
+		return false, nil
+	}
+
+	// the store assigns the ID
+	n := copyAccount(acc)
+	n.Id = 0
+	if err := m.insertAccount(n); err != nil {
+		return false, err
+	}
+	acc.Id = n.Id
+	return true, nil
+}
+
+// Get list of all account pairs ordered by name of the first account.
+func (m *Memory) AllPairs(ctx context.Context) ([]*models.AccountPair, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pairs := make([]*models.AccountPair, len(m.pairs))
+	for i, p := range m.pairs {
+		pairs[i] = m.accountPair(p)
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].One.Name < pairs[j].One.Name })
+	return pairs, nil
+}
+
+// Get account pair by ID; like the SQL store, an empty pair is returned for unknown ID.
+func (m *Memory) PairById(ctx context.Context, id int) (*models.AccountPair, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, p := range m.pairs {
+		if p.Id == int64(id) {
+			return m.accountPair(p), nil
+		}
+	}
+	return &models.AccountPair{One: new(models.Account), Two: new(models.Account)}, nil
+}
+
+// Get random account pair; like the SQL store, an empty pair is returned if there are no pairs.
+func (m *Memory) RandomPair(ctx context.Context) (*models.AccountPair, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if 0 == len(m.pairs) {
+		return &models.AccountPair{One: new(models.Account), Two: new(models.Account)}, nil
+	}
+	return m.accountPair(m.pairs[m.rnd.Intn(len(m.pairs))]), nil
+}
+
+// Add new pair of the given accounts; pairs already known in either direction are skipped.
+// Returns true if the pair has been added.
+func (m *Memory) AddPair(ctx context.Context, one int64, two int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range m.pairs {
+		if (p.One == one && p.Two == two) || (p.One == two && p.Two == one) {
+			return false, nil
+		}
+	}
+	if err := m.insertPair(&memoryPair{One: one, Two: two}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Find an active API key by the SHA-256 hash of the key.
+func (m *Memory) ApiKeyByHash(ctx context.Context, hash string) (*models.ApiKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	k := m.apiKeyByHash(hash)
+	if k == nil || (k.Active != nil && !*k.Active) {
+		return nil, ErrApiKeyNotFound
+	}
+	return &models.ApiKey{Id: k.Id, Name: k.Name, Hash: k.Hash, Roles: k.Roles}, nil
+}
+
+// Store new account checking the constraints of the SQL schema; the ID is assigned if not set.
+func (m *Memory) insertAccount(acc *models.Account) error {
+	if "" == acc.Name || "" == acc.Address {
+		return fmt.Errorf("account name and address are required")
+	}
+	if m.accountByAddress(acc.Address) != nil {
+		return fmt.Errorf("duplicate account address %s", acc.Address)
+	}
+
+	// assign the ID
+	if 0 == acc.Id {
+		acc.Id = m.lastAccountId + 1
+	}
+	if m.accountById(acc.Id) != nil {
+		return fmt.Errorf("duplicate account ID %d", acc.Id)
+	}
+	if acc.Id > m.lastAccountId {
+		m.lastAccountId = acc.Id
+	}
+
+	m.accounts = append(m.accounts, acc)
+	return nil
+}
+
+// Store new pair checking the accounts exist; the ID is assigned if not set.
+func (m *Memory) insertPair(p *memoryPair) error {
+	if m.accountById(p.One) == nil || m.accountById(p.Two) == nil {
+		return fmt.Errorf("pair of unknown accounts #%d and #%d", p.One, p.Two)
+	}
+
+	// assign the ID
+	if 0 == p.Id {
+		p.Id = m.lastPairId + 1
+	}
+	for _, x := range m.pairs {
+		if x.Id == p.Id {
+			return fmt.Errorf("duplicate pair ID %d", p.Id)
+		}
+	}
+	if p.Id > m.lastPairId {
+		m.lastPairId = p.Id
+	}
+
+	m.pairs = append(m.pairs, p)
+	return nil
+}
+
+// Get copies of the accounts ordered by name, skipping the given IDs.
+func (m *Memory) sortedAccounts(skip map[int64]bool) []*models.Account {
+	accounts := make([]*models.Account, 0, len(m.accounts))
+	for _, acc := range m.accounts {
+		if !skip[acc.Id] {
+			accounts = append(accounts, copyAccount(acc))
+		}
+	}
+	sort.SliceStable(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+	return accounts
+}
+
+// Find account by ID.
+func (m *Memory) accountById(id int64) *models.Account {
+	for _, acc := range m.accounts {
+		if acc.Id == id {
+			return acc
+		}
+	}
+	return nil
+}
+
+// Find account by address.
+func (m *Memory) accountByAddress(addr string) *models.Account {
+	for _, acc := range m.accounts {
+		if acc.Address == addr {
+			return acc
+		}
+	}
+	return nil
+}
+
+// Find API key by the hash.
+func (m *Memory) apiKeyByHash(hash string) *memoryApiKey {
+	for _, k := range m.apiKeys {
+		if k.Hash == hash {
+			return k
+		}
+	}
+	return nil
+}
+
+// Get the pair with accounts details; passwords are not included, same as in the SQL store.
+func (m *Memory) accountPair(p *memoryPair) *models.AccountPair {
+	one, two := copyAccount(m.accountById(p.One)), copyAccount(m.accountById(p.Two))
+	one.Password, two.Password = "", ""
+	return &models.AccountPair{One: one, Two: two}
+}
+
+// Make a copy of the account so callers can not change the stored one.
+func copyAccount(acc *models.Account) *models.Account {
+	c := *acc
+	return &c
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// define SQL queries used to maintain the schema
+const (
+	sqlSchemaVersion   string = "SELECT COALESCE(MAX(version), 0) FROM schema_migrations"
+	sqlInsertMigration string = "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)"
+	sqlDeleteMigration string = "DELETE FROM schema_migrations WHERE version=$1"
+)
+
+// Get version of the database schema; zero is returned if no migration has been applied yet.
+func (db *DB) SchemaVersion(ctx context.Context) (int, error) {
+	// do we have the migrations table at all?
+	var count int
+	err := db.read(ctx, db.dialect.schemaMigrationsExist, func(ctx context.Context) error {
+		return db.GetContext(ctx, &count, db.dialect.schemaMigrationsExist)
+	})
+	if err != nil || 0 == count {
+		return 0, err
+	}
+
+	// get the latest applied version
+	var version int
+	err = db.read(ctx, sqlSchemaVersion, func(ctx context.Context) error {
+		return db.GetContext(ctx, &version, sqlSchemaVersion)
+	})
+	return version, err
+}
+
+// Apply, or roll back, migrations to get the database schema to the target version.
+// Each migration is applied in its own transaction together with the version record.
+func (db *DB) Migrate(ctx context.Context, target int) error {
+	// is the target known?
+	if 0 != target && nil == findMigration(target) {
+		return fmt.Errorf("unknown schema version %d, latest known is %d", target, LatestSchemaVersion())
+	}
+
+	// make sure we have the migrations table
+	if _, err := db.ExecContext(ctx, db.dialect.createSchemaMigrations); err != nil {
+		db.log.Errorf("DB->Migrate(): Can not create migrations table. %s", err.Error())
+		return err
+	}
+
+	// where are we now
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if current > LatestSchemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than the latest known %d", current, LatestSchemaVersion())
+	}
+
+	// upgrade
+	for _, m := range migrations {
+		if m.version > current && m.version <= target {
+			if err := db.applyMigration(ctx, m.version, m.name, m.up[db.dialect.name], sqlInsertMigration, m.version, m.name); err != nil {
+				return err
+			}
+		}
+	}
+
+	// downgrade
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if m := migrations[i]; m.version <= current && m.version > target {
+			if err := db.applyMigration(ctx, m.version, m.name, m.down[db.dialect.name], sqlDeleteMigration, m.version); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Execute statements of a migration and update the version record in a single transaction.
+func (db *DB) applyMigration(ctx context.Context, version int, name string, statements []string, record string, args ...interface{}) error {
+	// a migration missing for the dialect must not be recorded as applied
+	if 0 == len(statements) {
+		db.log.Errorf("DB->Migrate(): Migration #%d has no statements for %s.", version, db.dialect.name)
+		return fmt.Errorf("migration #%d %s has no statements for %s database", version, name, db.dialect.name)
+	}
+
+	db.log.Noticef("DB->Migrate(): Migrating schema #%d %s.", version, name)
+
+	// start the transaction
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		db.log.Errorf("DB->Migrate(): Can not start transaction. %s", err.Error())
+		return err
+	}
+
+	// execute the statements
+	for _, st := range statements {
+		if _, err = tx.ExecContext(ctx, st); err != nil {
+			break
+		}
+	}
+
+	// keep track of the version
+	if err == nil {
+		_, err = tx.ExecContext(ctx, record, args...)
+	}
+
+	// anything went wrong?
+	if err != nil {
+		db.log.Errorf("DB->Migrate(): Migration #%d failed. %s", version, err.Error())
+		_ = tx.Rollback()
+		return fmt.Errorf("migration #%d %s failed; %s", version, name, err.Error())
+	}
+	return tx.Commit()
+}
+
+// Find migration of the given version.
+func findMigration(version int) *migration {
+	for i := range migrations {
+		if migrations[i].version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
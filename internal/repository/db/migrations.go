@@ -0,0 +1,96 @@
+package db
+
+// Define a versioned change of the database schema with statements for each SQL dialect.
+// Statements of each direction are executed in a single transaction; every dialect needs both directions,
+// a migration without statements for the current dialect fails instead of being recorded as applied.
+type migration struct {
+	version int
+	name    string
+	up      map[string][]string
+	down    map[string][]string
+}
+
+// list of known schema migrations, ordered by version; never change an applied migration, add a new one
+var migrations = []migration{
+	{
+		// the baseline matching the schema from docs/database; existing objects are adopted as they are
+		version: 1,
+		name:    "accounts, pairs and API keys",
+		up: map[string][]string{
+			postgresDriver: {
+				`CREATE SEQUENCE IF NOT EXISTS seq_account INCREMENT 1 START 1`,
+				`CREATE SEQUENCE IF NOT EXISTS seq_account_pair INCREMENT 1 START 1`,
+				`CREATE SEQUENCE IF NOT EXISTS seq_api_key INCREMENT 1 START 1`,
+				`CREATE TABLE IF NOT EXISTS account (
+					id bigint NOT NULL DEFAULT NEXTVAL('seq_account'::regclass),
+					name varchar(50) NOT NULL,
+					address varchar(100) NOT NULL,
+					pwd varchar(100) NOT NULL,
+					CONSTRAINT "PK_account" PRIMARY KEY (id))`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS "IX_account_hash" ON account (address ASC)`,
+				`CREATE TABLE IF NOT EXISTS account_pair (
+					id bigint NOT NULL DEFAULT NEXTVAL('seq_account_pair'::regclass),
+					account_id_left bigint NOT NULL,
+					account_id_right bigint NOT NULL,
+					CONSTRAINT "PK_account_pair" PRIMARY KEY (id),
+					CONSTRAINT "FK_account_pair_account" FOREIGN KEY (account_id_left) REFERENCES account (id) ON DELETE CASCADE,
+					CONSTRAINT "FK_account_pair_account_02" FOREIGN KEY (account_id_right) REFERENCES account (id) ON DELETE CASCADE)`,
+				`CREATE INDEX IF NOT EXISTS "IXFK_account_pair_account" ON account_pair (account_id_left ASC)`,
+				`CREATE INDEX IF NOT EXISTS "IXFK_account_pair_account_02" ON account_pair (account_id_right ASC)`,
+				`COMMENT ON TABLE account_pair IS 'Binds two accounts together to form testing pair.'`,
+				`CREATE TABLE IF NOT EXISTS api_key (
+					id bigint NOT NULL DEFAULT NEXTVAL('seq_api_key'::regclass),
+					name varchar(50) NOT NULL,
+					key_hash varchar(64) NOT NULL,
+					roles varchar(100) NOT NULL DEFAULT 'operator',
+					active boolean NOT NULL DEFAULT true,
+					CONSTRAINT "PK_api_key" PRIMARY KEY (id))`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS "IX_api_key_hash" ON api_key (key_hash ASC)`,
+				`COMMENT ON TABLE api_key IS 'API keys of callers allowed to use mutations; only SHA-256 hashes of the keys are stored.'`,
+			},
+			sqliteDriver: {
+				`CREATE TABLE IF NOT EXISTS account (
+					id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+					name varchar(50) NOT NULL,
+					address varchar(100) NOT NULL,
+					pwd varchar(100) NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS "IX_account_hash" ON account (address ASC)`,
+				`CREATE TABLE IF NOT EXISTS account_pair (
+					id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+					account_id_left bigint NOT NULL,
+					account_id_right bigint NOT NULL,
+					CONSTRAINT "FK_account_pair_account" FOREIGN KEY (account_id_left) REFERENCES account (id) ON DELETE CASCADE,
+					CONSTRAINT "FK_account_pair_account_02" FOREIGN KEY (account_id_right) REFERENCES account (id) ON DELETE CASCADE)`,
+				`CREATE INDEX IF NOT EXISTS "IXFK_account_pair_account" ON account_pair (account_id_left ASC)`,
+				`CREATE INDEX IF NOT EXISTS "IXFK_account_pair_account_02" ON account_pair (account_id_right ASC)`,
+				`CREATE TABLE IF NOT EXISTS api_key (
+					id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+					name varchar(50) NOT NULL,
+					key_hash varchar(64) NOT NULL,
+					roles varchar(100) NOT NULL DEFAULT 'operator',
+					active boolean NOT NULL DEFAULT 1)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS "IX_api_key_hash" ON api_key (key_hash ASC)`,
+			},
+		},
+		down: map[string][]string{
+			postgresDriver: {
+				`DROP TABLE IF EXISTS api_key`,
+				`DROP TABLE IF EXISTS account_pair`,
+				`DROP TABLE IF EXISTS account`,
+				`DROP SEQUENCE IF EXISTS seq_api_key`,
+				`DROP SEQUENCE IF EXISTS seq_account_pair`,
+				`DROP SEQUENCE IF EXISTS seq_account`,
+			},
+			sqliteDriver: {
+				`DROP TABLE IF EXISTS api_key`,
+				`DROP TABLE IF EXISTS account_pair`,
+				`DROP TABLE IF EXISTS account`,
+			},
+		},
+	},
+}
+
+// Get the latest schema version known to this build.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
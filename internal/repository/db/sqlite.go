@@ -0,0 +1,7 @@
+//go:build sqlite
+// +build sqlite
+
+package db
+
+// the SQLite driver needs cgo, so it's built in only with the sqlite tag
+import _ "github.com/mattn/go-sqlite3"
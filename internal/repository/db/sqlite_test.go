@@ -0,0 +1,51 @@
+//go:build sqlite
+// +build sqlite
+
+package db_test
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/repository/db"
+	"github.com/op/go-logging"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// the SQLite store is tested only if the driver is built in
+func init() {
+	testStores["sqlite"] = newSqliteTestStore
+}
+
+// Create SQLite store in a temporary file migrated to the latest schema; the API key is inserted directly.
+func newSqliteTestStore(t *testing.T) (db.DataStore, func()) {
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &common.Config{DbDriver: "sqlite", DbName: filepath.Join(dir, "test.db"), DbMaxOpenConnections: 1, DbTimeout: 5 * time.Second}
+	store, err := db.OpenDB(cfg, logging.MustGetLogger("test"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	done := func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+
+	ctx := context.Background()
+	if err := store.Migrate(ctx, db.LatestSchemaVersion()); err != nil {
+		done()
+		t.Fatal(err)
+	}
+	if _, err := store.ExecContext(ctx, "INSERT INTO api_key (name, key_hash, roles) VALUES ($1, $2, $3)", "conformance", testKeyHash, "viewer"); err != nil {
+		done()
+		t.Fatal(err)
+	}
+	return store, done
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"fantomrocks-api/internal/common"
 	dbRepo "fantomrocks-api/internal/repository/db"
 	rpcRepo "fantomrocks-api/internal/repository/rpc"
@@ -30,5 +31,51 @@ func NewRepository(cfg *common.Config, log services.Logger) (*Repository, error)
 		return nil, err
 	}
 
+	// keep finalized blocks and transactions in memory if enabled
+	if 0 < cfg.RpcCacheSize {
+		rpc, err = rpcRepo.NewCache(rpc, cfg.RpcCacheSize, log)
+		if err != nil {
+			log.Criticalf("NewEnv(): RPC cache not available, can not proceed! %s", err.Error())
+			return nil, err
+		}
+	}
+
+	// serve balances from a shared cache if enabled
+	if 0 < cfg.BalanceTTL {
+		rpc = rpcRepo.NewBalanceCache(rpc, cfg.BalanceTTL, cfg.BalanceOnBlock, accountAddresses(db), log)
+	}
+
 	return &Repository{Db: db, Rpc: rpc, Log: log}, nil
 }
+
+// Stop background work of the repository adapters, if any, and close their connections.
+func (repo *Repository) Close() {
+	if c, ok := repo.Rpc.(interface{ Close() }); ok {
+		c.Close()
+	}
+
+	// the in-memory store has nothing to close
+	if c, ok := repo.Db.(interface{ Close() error }); ok {
+		if err := c.Close(); err != nil {
+			repo.Log.Errorf("Repository->Close(): Database not closed. %s", err.Error())
+		}
+	}
+}
+
+// Get function providing addresses of all the accounts in the database.
+func accountAddresses(db dbRepo.DataStore) func(context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		// get all the accounts
+		accounts, err := db.AllAccounts(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// extract addresses
+		addrs := make([]string, len(accounts))
+		for i, acc := range accounts {
+			addrs[i] = acc.Address
+		}
+		return addrs, nil
+	}
+}
@@ -1,27 +1,121 @@
 package rpc
 
 import (
+	"context"
 	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/services"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
 	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
+	"math/big"
 )
 
 // Get Account balance from block-chain node
-func (rpc *Rpc) AccountBalance(addr string) (*models.Amount, error) {
+func (rpc *Rpc) AccountBalance(ctx context.Context, addr string) (*models.Amount, error) {
+	// trace the call
+	ctx, span := rpc.trace(ctx, "AccountBalance", attribute.String("account.address", addr))
+	defer span.End()
+
 	// use RPC to make the call
 	var balance string
-	err := rpc.Call(&balance, "ftm_getBalance", addr, "latest")
+	err := rpc.CallContext(ctx, &balance, "ftm_getBalance", addr, "latest")
 	if err != nil {
-		rpc.log.Errorf("RPC->AccountBalance(): Error [%s]", err.Error())
+		rpc.logger(ctx).Errorf("RPC->AccountBalance(): Error [%s]", err.Error())
+		services.SpanError(span, err)
 		return &models.Amount{}, err
 	}
 
 	// decode the response
 	val, err := hexutil.DecodeBig(balance)
 	if err != nil {
-		rpc.log.Errorf("RPC->AccountBalance(): Can not get account balance for [%s]. %s", addr, err.Error())
+		rpc.logger(ctx).Errorf("RPC->AccountBalance(): Can not get account balance for [%s]. %s", addr, err.Error())
+		services.SpanError(span, err)
 		return &models.Amount{}, err
 	}
 
 	return &models.Amount{Decimal: decimal.NewFromBigInt(val, 0)}, nil
 }
+
+// Get Account balance including transactions not mined yet; it is never cached.
+// The write end point is asked, it knows all the transactions we have sent.
+func (rpc *Rpc) PendingBalance(ctx context.Context, addr string) (*models.Amount, error) {
+	// trace the call
+	ctx, span := rpc.trace(ctx, "PendingBalance", attribute.String("account.address", addr))
+	defer span.End()
+
+	// use RPC to make the call
+	var balance hexutil.Big
+	err := rpc.WriteCallContext(ctx, &balance, "ftm_getBalance", addr, "pending")
+	if err != nil {
+		rpc.logger(ctx).Errorf("RPC->PendingBalance(): Can not get pending balance for [%s]. %s", addr, err.Error())
+		services.SpanError(span, err)
+		return &models.Amount{}, err
+	}
+
+	val := big.Int(balance)
+	return &models.Amount{Decimal: decimal.NewFromBigInt(&val, 0)}, nil
+}
+
+// Get balances of given list of Accounts from block-chain node using a batched call.
+// The balances are returned in the order of addresses; errors are reported per account.
+func (rpc *Rpc) AccountBalances(ctx context.Context, addrs []string) ([]*models.Amount, []error) {
+	// trace the call
+	ctx, span := rpc.trace(ctx, "AccountBalances", attribute.Int("batch.size", len(addrs)))
+	defer span.End()
+
+	// inform
+	rpc.logger(ctx).Debugf("RPC->AccountBalances(): Loading %d balances in a batch", len(addrs))
+
+	// prep the batch
+	raw := make([]hexutil.Big, len(addrs))
+	batch := make([]ethrpc.BatchElem, len(addrs))
+	for i, addr := range addrs {
+		batch[i] = ethrpc.BatchElem{Method: "ftm_getBalance", Args: []interface{}{addr, "latest"}, Result: &raw[i]}
+	}
+
+	// make the call
+	res := make([]*models.Amount, len(addrs))
+	errs := make([]error, len(addrs))
+	if err := rpc.BatchCallContext(ctx, batch); err != nil {
+		rpc.logger(ctx).Errorf("RPC->AccountBalances(): Error [%s]", err.Error())
+		services.SpanError(span, err)
+		for i := range errs {
+			res[i], errs[i] = &models.Amount{}, err
+		}
+		return res, errs
+	}
+
+	// build the result set
+	for i, el := range batch {
+		if el.Error != nil {
+			res[i], errs[i] = &models.Amount{}, el.Error
+			continue
+		}
+
+		val := big.Int(raw[i])
+		res[i] = &models.Amount{Decimal: decimal.NewFromBigInt(&val, 0)}
+	}
+
+	return res, errs
+}
+
+// Create new account protected by the password in the key store of the node
+// handling writes and get its address.
+func (rpc *Rpc) NewAccount(ctx context.Context, password string) (string, error) {
+	// trace the call
+	ctx, span := rpc.trace(ctx, "NewAccount")
+	defer span.End()
+
+	// use RPC to make the call
+	var addr string
+	err := rpc.WriteCallContext(ctx, &addr, "personal_newAccount", password)
+	if err != nil {
+		rpc.logger(ctx).Errorf("RPC->NewAccount(): Error [%s]", err.Error())
+		services.SpanError(span, err)
+		return "", err
+	}
+
+	rpc.logger(ctx).Debugf("RPC->NewAccount(): Account %s created.", addr)
+	return addr, nil
+}
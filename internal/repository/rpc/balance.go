@@ -0,0 +1,242 @@
+package rpc
+
+import (
+	"context"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/services"
+	"sync"
+	"time"
+)
+
+// how often we check for a new block if balances are refreshed on new blocks
+const balanceBlockPollInterval = 500 * time.Millisecond
+
+// Block-Chain adapter serving Account balances from a short lived cache shared by all the requests.
+// Balances of all known accounts are refreshed in a single batch once the TTL expires,
+// so the node load does not depend on the number of connected clients.
+type BalanceCache struct {
+	BlockChain
+	log      services.Logger
+	ttl      time.Duration
+	accounts func(context.Context) ([]string, error)
+
+	mu       sync.RWMutex
+	balances map[string]*cachedBalance
+
+	// invalidation bumps the generation of the address; balances loaded before are stale and dropped
+	generations map[string]uint64
+
+	// the refresh loop runs until the cache is closed
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Define balance kept in the cache.
+type cachedBalance struct {
+	amount *models.Amount
+	loaded time.Time
+}
+
+// Wrap the given block-chain adapter with balances cache.
+// The accounts function provides addresses of all the accounts to be refreshed.
+// If onBlock is set, balances are refreshed also when a new block arrives.
+func NewBalanceCache(chain BlockChain, ttl time.Duration, onBlock bool, accounts func(context.Context) ([]string, error), log services.Logger) *BalanceCache {
+	ctx, cancel := context.WithCancel(context.Background())
+	bc := &BalanceCache{
+		BlockChain:  chain,
+		log:         log,
+		ttl:         ttl,
+		accounts:    accounts,
+		balances:    make(map[string]*cachedBalance),
+		generations: make(map[string]uint64),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+
+	// start refreshing
+	go bc.run(ctx, onBlock)
+
+	log.Debugf("NewBalanceCache(): Balances cache ready with TTL %s.", ttl)
+	return bc
+}
+
+// Get Account balance from the cache, or from the node if not known.
+func (bc *BalanceCache) AccountBalance(ctx context.Context, addr string) (*models.Amount, error) {
+	// do we have a fresh balance?
+	if bal := bc.get(addr); bal != nil {
+		return bal, nil
+	}
+
+	// load the balance from the node
+	gen := bc.generation(addr)
+	bal, err := bc.BlockChain.AccountBalance(ctx, addr)
+	if err == nil {
+		bc.set(addr, bal, gen[0])
+	}
+	return bal, err
+}
+
+// Get balances of given list of Accounts from the cache; unknown balances are loaded from the node in a batch.
+func (bc *BalanceCache) AccountBalances(ctx context.Context, addrs []string) ([]*models.Amount, []error) {
+	res := make([]*models.Amount, len(addrs))
+	errs := make([]error, len(addrs))
+
+	// collect balances we don't have
+	missing := make([]string, 0, len(addrs))
+	index := make([]int, 0, len(addrs))
+	for i, addr := range addrs {
+		if bal := bc.get(addr); bal != nil {
+			res[i] = bal
+			continue
+		}
+
+		missing = append(missing, addr)
+		index = append(index, i)
+	}
+
+	// anything to load?
+	if 0 == len(missing) {
+		return res, errs
+	}
+
+	// load them in a batch and merge with cached ones
+	gen := bc.generation(missing...)
+	bal, berr := bc.BlockChain.AccountBalances(ctx, missing)
+	for j, b := range bal {
+		res[index[j]], errs[index[j]] = b, berr[j]
+		if berr[j] == nil {
+			bc.set(missing[j], b, gen[j])
+		}
+	}
+
+	return res, errs
+}
+
+// Make a transfer of given amount of tokens; balances of both accounts are dropped
+// from the cache so the next read gets them from the node.
+func (bc *BalanceCache) TransferTokens(ctx context.Context, from *models.Account, to *models.Account, amount models.Amount) (*models.Transaction, error) {
+	tr, err := bc.BlockChain.TransferTokens(ctx, from, to, amount)
+	if err == nil {
+		bc.invalidate(from.Address, to.Address)
+	}
+	return tr, err
+}
+
+// Stop refreshing balances and wait for the refresh in progress to finish; the wrapped adapter is closed too.
+func (bc *BalanceCache) Close() {
+	bc.cancel()
+	<-bc.done
+	closeChain(bc.BlockChain)
+}
+
+// Get a fresh balance from the cache; nil if not available.
+func (bc *BalanceCache) get(addr string) *models.Amount {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	// we tolerate one missed refresh before we go to the node again
+	cb, ok := bc.balances[addr]
+	if !ok || time.Since(cb.loaded) > 2*bc.ttl {
+		return nil
+	}
+	return cb.amount
+}
+
+// Get the current generations of the given addresses; take them before the balances are loaded.
+func (bc *BalanceCache) generation(addrs ...string) []uint64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	gen := make([]uint64, len(addrs))
+	for i, addr := range addrs {
+		gen[i] = bc.generations[addr]
+	}
+	return gen
+}
+
+// Store the balance loaded at the given generation in the cache;
+// the balance is dropped if the address was invalidated while it was loading.
+func (bc *BalanceCache) set(addr string, amount *models.Amount, gen uint64) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if bc.generations[addr] != gen {
+		return
+	}
+	bc.balances[addr] = &cachedBalance{amount: amount, loaded: time.Now()}
+}
+
+// Drop balances of the given addresses from the cache, including these being loaded right now.
+func (bc *BalanceCache) invalidate(addrs ...string) {
+	bc.mu.Lock()
+	for _, addr := range addrs {
+		delete(bc.balances, addr)
+		bc.generations[addr]++
+	}
+	bc.mu.Unlock()
+}
+
+// Refresh balances periodically and on new blocks, if requested, until the context is cancelled.
+func (bc *BalanceCache) run(ctx context.Context, onBlock bool) {
+	defer close(bc.done)
+
+	// prep timers
+	refresh := time.NewTicker(bc.ttl)
+	defer refresh.Stop()
+
+	// we check for new blocks only if requested
+	var blocks <-chan time.Time
+	if onBlock {
+		poll := time.NewTicker(balanceBlockPollInterval)
+		defer poll.Stop()
+		blocks = poll.C
+	}
+
+	// refresh right away so the first clients don't wait
+	bc.refresh(ctx)
+
+	var height uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-refresh.C:
+			bc.refresh(ctx)
+		case <-blocks:
+			// did we get a new block?
+			h, err := bc.BlockChain.BlockHeight(ctx)
+			if err != nil || h == height {
+				continue
+			}
+
+			height = h
+			bc.refresh(ctx)
+		}
+	}
+}
+
+// Refresh balances of all known accounts in a single batch; the refresh is not bound to any request.
+func (bc *BalanceCache) refresh(ctx context.Context) {
+	// get the accounts
+	addrs, err := bc.accounts(ctx)
+	if err != nil {
+		bc.log.Errorf("BalanceCache(): Can not get list of accounts. %s", err.Error())
+		return
+	}
+
+	// anything to do?
+	if 0 == len(addrs) {
+		return
+	}
+
+	// load the balances; a transfer may invalidate some of them meanwhile
+	gen := bc.generation(addrs...)
+	bal, errs := bc.BlockChain.AccountBalances(ctx, addrs)
+	for i, b := range bal {
+		if errs[i] == nil {
+			bc.set(addrs[i], b, gen[i])
+		}
+	}
+
+	bc.log.Debugf("BalanceCache(): %d balances refreshed.", len(addrs))
+}
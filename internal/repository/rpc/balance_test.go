@@ -0,0 +1,80 @@
+package rpc_test
+
+import (
+	"context"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/repository/rpc"
+	"github.com/op/go-logging"
+	"github.com/shopspring/decimal"
+	"sync"
+	"testing"
+	"time"
+)
+
+// address of the only account known to the balance cache under test
+const gatedAccount = "0x1000000000000000000000000000000000000001"
+
+// Define block chain holding batch loads of balances until released; other calls are served right away.
+type gatedChain struct {
+	rpc.BlockChain
+	mu      sync.Mutex
+	balance int64
+	loading chan struct{}
+	release chan struct{}
+}
+
+// Get the balance the batch load started with; the load returns once released.
+func (gc *gatedChain) AccountBalances(_ context.Context, addrs []string) ([]*models.Amount, []error) {
+	gc.mu.Lock()
+	bal := gc.balance
+	gc.mu.Unlock()
+
+	gc.loading <- struct{}{}
+	<-gc.release
+
+	res := make([]*models.Amount, len(addrs))
+	for i := range addrs {
+		res[i] = &models.Amount{Decimal: decimal.NewFromInt(bal)}
+	}
+	return res, make([]error, len(addrs))
+}
+
+func (gc *gatedChain) AccountBalance(_ context.Context, _ string) (*models.Amount, error) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	return &models.Amount{Decimal: decimal.NewFromInt(gc.balance)}, nil
+}
+
+func (gc *gatedChain) TransferTokens(_ context.Context, from *models.Account, to *models.Account, amount models.Amount) (*models.Transaction, error) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.balance -= amount.IntPart()
+	return &models.Transaction{FromAccount: from, ToAccount: to, Amount: &amount}, nil
+}
+
+// Refresh started before a transfer must not put the balance from before the transfer back into the cache.
+func TestBalanceCacheRefreshAfterTransfer(t *testing.T) {
+	chain := &gatedChain{balance: 100, loading: make(chan struct{}), release: make(chan struct{})}
+	accounts := func(context.Context) ([]string, error) { return []string{gatedAccount}, nil }
+	bc := rpc.NewBalanceCache(chain, time.Hour, false, accounts, logging.MustGetLogger("test"))
+	defer bc.Close()
+
+	// the first refresh starts right away; make the transfer while it's loading
+	<-chain.loading
+	acc := &models.Account{Address: gatedAccount}
+	if _, err := bc.TransferTokens(context.Background(), acc, &models.Account{}, models.Amount{Decimal: decimal.NewFromInt(30)}); err != nil {
+		t.Fatal(err)
+	}
+	chain.release <- struct{}{}
+
+	// wait for the refresh to finish; closing waits for the refresh loop
+	bc.Close()
+
+	bal, err := bc.AccountBalance(context.Background(), gatedAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bal.IntPart() != 70 {
+		t.Errorf("expected balance 70, got %s", bal.String())
+	}
+}
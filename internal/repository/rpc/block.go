@@ -1,16 +1,24 @@
 package rpc
 
 import (
+	"context"
 	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/services"
+	"fmt"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/graph-gophers/graphql-go"
+	"go.opentelemetry.io/otel/attribute"
 	"time"
 )
 
 // Get a raw Transaction information for given tx hash.
-func (rpc *Rpc) BlockByHash(hash string) (*models.BcBlock, error) {
+func (rpc *Rpc) BlockByHash(ctx context.Context, hash string) (*models.BcBlock, error) {
+	// trace the call
+	ctx, span := rpc.trace(ctx, "BlockByHash", attribute.String("block.hash", hash))
+	defer span.End()
+
 	// unlock the source account
-	rpc.log.Debugf("RPC->BlockByHash(): Loading block details for [%s]", hash)
+	rpc.logger(ctx).Debugf("RPC->BlockByHash(): Loading block details for [%s]", hash)
 
 	// container for raw data
 	var raw struct {
@@ -24,9 +32,18 @@ func (rpc *Rpc) BlockByHash(hash string) (*models.BcBlock, error) {
 	}
 
 	// call for data
-	err := rpc.Call(&raw, "eth_getBlockByHash", hash, false)
+	err := rpc.CallContext(ctx, &raw, "eth_getBlockByHash", hash, false)
 	if err != nil {
-		rpc.log.Errorf("RPC->BlockByHash(): Error! %s", err.Error())
+		rpc.logger(ctx).Errorf("RPC->BlockByHash(): Error! %s", err.Error())
+		services.SpanError(span, err)
+		return nil, err
+	}
+
+	// the node answers null for unknown blocks
+	if raw.Hash == "" {
+		err = fmt.Errorf("block %s not found", hash)
+		rpc.logger(ctx).Errorf("RPC->BlockByHash(): Error! %s", err.Error())
+		services.SpanError(span, err)
 		return nil, err
 	}
 
@@ -38,3 +55,21 @@ func (rpc *Rpc) BlockByHash(hash string) (*models.BcBlock, error) {
 		TxHashes:  raw.Transactions,
 	}, nil
 }
+
+// Get the number of the most recent block known to the node.
+func (rpc *Rpc) BlockHeight(ctx context.Context) (uint64, error) {
+	// trace the call
+	ctx, span := rpc.trace(ctx, "BlockHeight")
+	defer span.End()
+
+	// call for data
+	var height hexutil.Uint64
+	err := rpc.CallContext(ctx, &height, "eth_blockNumber")
+	if err != nil {
+		rpc.logger(ctx).Errorf("RPC->BlockHeight(): Error! %s", err.Error())
+		services.SpanError(span, err)
+		return 0, err
+	}
+
+	return uint64(height), nil
+}
@@ -0,0 +1,145 @@
+package rpc
+
+import (
+	"context"
+	"expvar"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/services"
+	"github.com/hashicorp/golang-lru"
+)
+
+// prefixes of cache keys for different kinds of data
+const (
+	cacheKeyBlock       = "blk:"
+	cacheKeyTransaction = "trx:"
+)
+
+// cache counters published on the /debug/vars end point
+var cacheStats = expvar.NewMap("rpc_cache")
+
+// Block-Chain adapter keeping finalized blocks and transactions in memory.
+// Blocks and transactions included in a block never change so we don't need
+// to ask the node for them again. Pending transactions are never cached.
+type Cache struct {
+	BlockChain
+	log   services.Logger
+	cache *lru.Cache
+}
+
+// Wrap the given block-chain adapter with an LRU cache of given size.
+func NewCache(chain BlockChain, size int, log services.Logger) (*Cache, error) {
+	// make the LRU cache
+	cache, err := lru.New(size)
+	if err != nil {
+		log.Criticalf("NewCache(): Can not create RPC cache. %s", err.Error())
+		return nil, err
+	}
+
+	log.Debugf("NewCache(): RPC cache ready for %d entries.", size)
+	return &Cache{BlockChain: chain, log: log, cache: cache}, nil
+}
+
+// Close the wrapped adapter.
+func (c *Cache) Close() {
+	closeChain(c.BlockChain)
+}
+
+// Get a raw Block information for given block hash.
+func (c *Cache) BlockByHash(ctx context.Context, hash string) (*models.BcBlock, error) {
+	// do we have the block?
+	if blk, ok := c.get(cacheKeyBlock + hash); ok {
+		return blk.(*models.BcBlock), nil
+	}
+
+	// load the block from the node
+	blk, err := c.BlockChain.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// blocks are final once we have them
+	c.cache.Add(cacheKeyBlock+hash, blk)
+	return blk, nil
+}
+
+// Get a raw Transaction information for given tx hash.
+func (c *Cache) TransactionByHash(ctx context.Context, hash string) (*models.BcTransaction, error) {
+	// do we have the transaction?
+	if tx, ok := c.get(cacheKeyTransaction + hash); ok {
+		return tx.(*models.BcTransaction), nil
+	}
+
+	// load the transaction from the node
+	tx, err := c.BlockChain.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	c.addTransaction(tx)
+	return tx, nil
+}
+
+// Get raw Transactions information for given list of tx hashes.
+// Only transactions we don't have are loaded from the node.
+func (c *Cache) TransactionsByHash(ctx context.Context, hashes []string) ([]*models.BcTransaction, []error) {
+	res := make([]*models.BcTransaction, len(hashes))
+	errs := make([]error, len(hashes))
+
+	// collect transactions we don't know
+	missing := make([]string, 0, len(hashes))
+	index := make([]int, 0, len(hashes))
+	for i, h := range hashes {
+		if tx, ok := c.get(cacheKeyTransaction + h); ok {
+			res[i] = tx.(*models.BcTransaction)
+			continue
+		}
+
+		missing = append(missing, h)
+		index = append(index, i)
+	}
+
+	// anything to load?
+	if 0 == len(missing) {
+		return res, errs
+	}
+
+	// load them in a batch and merge with cached ones
+	trx, terr := c.BlockChain.TransactionsByHash(ctx, missing)
+	for j, tx := range trx {
+		res[index[j]], errs[index[j]] = tx, terr[j]
+		if terr[j] == nil {
+			c.addTransaction(tx)
+		}
+	}
+
+	return res, errs
+}
+
+// Get hits and misses counters of the cache.
+func (c *Cache) Stats() (hits int64, misses int64) {
+	if v, ok := cacheStats.Get("hits").(*expvar.Int); ok {
+		hits = v.Value()
+	}
+	if v, ok := cacheStats.Get("misses").(*expvar.Int); ok {
+		misses = v.Value()
+	}
+	return hits, misses
+}
+
+// Get the cached value and update hits and misses counters.
+func (c *Cache) get(key string) (interface{}, bool) {
+	val, ok := c.cache.Get(key)
+	if ok {
+		cacheStats.Add("hits", 1)
+	} else {
+		cacheStats.Add("misses", 1)
+	}
+	return val, ok
+}
+
+// Add the transaction to the cache, if it's already included in a block.
+func (c *Cache) addTransaction(tx *models.BcTransaction) {
+	if tx != nil && tx.BlockHash != nil {
+		c.cache.Add(cacheKeyTransaction+tx.Hash, tx)
+	}
+}
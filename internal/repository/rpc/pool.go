@@ -0,0 +1,388 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// how long we wait for a node to respond to a health check
+const poolHealthCheckTimeout = 5 * time.Second
+
+// Define pool of block-chain node end points with health-checked failover.
+// Reads are routed to the healthiest end point in sync with the network and fail over
+// to other end points on connection errors. Writes go to the preferred write end point;
+// it holds the accounts keys, so it is used for writes even if it lags behind the others.
+type Pool struct {
+	log       services.Logger
+	endpoints []*endpoint
+	writer    *endpoint
+	maxLag    uint64
+	dial      Dialer
+
+	// calls timeout and retry policy
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+
+	// routing order of end points; the best end point goes first
+	mu     sync.RWMutex
+	routes []*endpoint
+
+	// the health monitor runs until the pool is closed
+	stop   chan struct{}
+	done   chan struct{}
+	closed sync.Once
+}
+
+// Define single block-chain node end point.
+type endpoint struct {
+	url string
+
+	mu      sync.RWMutex
+	client  *ethrpc.Client
+	height  uint64
+	healthy bool
+}
+
+// Create new pool of end points connected by the dialer; the write end point is added to the pool if not listed.
+// At least one of the end points has to be available.
+func NewPool(urls []string, writeUrl string, maxLag uint64, interval time.Duration, dial Dialer, log services.Logger) (*Pool, error) {
+	// prep the pool
+	p := &Pool{log: log, maxLag: maxLag, dial: dial, endpoints: make([]*endpoint, 0, len(urls)+1), stop: make(chan struct{}), done: make(chan struct{})}
+	for _, url := range urls {
+		p.endpoints = append(p.endpoints, &endpoint{url: url})
+	}
+
+	// find the write end point; the first one is used if not set
+	for _, ep := range p.endpoints {
+		if ep.url == writeUrl {
+			p.writer = ep
+		}
+	}
+	if p.writer == nil && "" != writeUrl {
+		p.writer = &endpoint{url: writeUrl}
+		p.endpoints = append(p.endpoints, p.writer)
+	}
+	if p.writer == nil && 0 < len(p.endpoints) {
+		p.writer = p.endpoints[0]
+	}
+
+	// we need at least something
+	if 0 == len(p.endpoints) {
+		return nil, fmt.Errorf("no RPC end point configured")
+	}
+
+	// check all the end points and make sure we have a working one
+	p.check()
+	if !p.available() {
+		return nil, fmt.Errorf("no RPC end point available")
+	}
+
+	// keep checking
+	go p.monitor(interval)
+	return p, nil
+}
+
+// Set timeout of a single call and retry policy for reads.
+// Failed reads are retried up to <retries> times, the backoff doubles with each retry.
+func (p *Pool) SetTimeouts(timeout time.Duration, retries int, backoff time.Duration) {
+	p.timeout, p.retries, p.backoff = timeout, retries, backoff
+}
+
+// Stop checking end points health and close connections to them.
+func (p *Pool) Close() {
+	p.closed.Do(func() {
+		close(p.stop)
+		<-p.done
+
+		for _, ep := range p.endpoints {
+			if c := ep.getClient(); c != nil {
+				c.Close()
+			}
+		}
+	})
+}
+
+// Make a call to the healthiest end point; other end points are tried on connection failure.
+func (p *Pool) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	return p.read(ctx, func(ctx context.Context, c *ethrpc.Client) error {
+		return c.CallContext(ctx, result, method, args...)
+	})
+}
+
+// Make a batch call to the healthiest end point; other end points are tried on connection failure.
+func (p *Pool) BatchCallContext(ctx context.Context, b []ethrpc.BatchElem) error {
+	return p.read(ctx, func(ctx context.Context, c *ethrpc.Client) error {
+		return c.BatchCallContext(ctx, b)
+	})
+}
+
+// Make a writing call to the preferred write end point.
+// Writes are never repeated on failure since we can not know if the node processed them,
+// and never sent elsewhere; accounts used to sign transactions live in the keystore of the write node.
+func (p *Pool) WriteCallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	// the writer has to be alive
+	ep := p.writer
+	c := ep.getClient()
+	if c == nil || !ep.isHealthy() {
+		p.logger(ctx).Errorf("RPC->Pool(): Write end point [%s] is down, %s call refused.", ep.url, method)
+		return fmt.Errorf("write RPC end point is not available")
+	}
+
+	// make the call
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err := c.CallContext(ctx, result, method, args...)
+	if err != nil && isConnectionError(ctx, err) {
+		ep.setHealthy(false, 0)
+	}
+	return err
+}
+
+// Execute idempotent read call, retrying with backoff if no end point answered.
+func (p *Pool) read(ctx context.Context, call func(context.Context, *ethrpc.Client) error) error {
+	backoff := p.backoff
+	for attempt := 0; ; attempt++ {
+		// try all end points
+		err := p.do(ctx, call)
+		if err == nil || !isConnectionError(ctx, err) || attempt >= p.retries {
+			return err
+		}
+
+		// wait before the next attempt, unless the caller gave up
+		p.logger(ctx).Warningf("RPC->Pool(): Call failed, retrying in %s. %s", backoff, err.Error())
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(attribute.String("error", err.Error())))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// Execute the call on end points in the routing order until one of them answers.
+func (p *Pool) do(ctx context.Context, call func(context.Context, *ethrpc.Client) error) error {
+	var err error
+	for _, ep := range p.order() {
+		// skip end points we could not connect to
+		c := ep.getClient()
+		if c == nil {
+			continue
+		}
+
+		// try the call; the node responded if we've got no error, or an error from the node itself
+		err = p.try(ctx, c, call)
+		if err == nil || !isConnectionError(ctx, err) {
+			return err
+		}
+
+		// mark the end point down and try the next one
+		p.logger(ctx).Warningf("RPC->Pool(): End point [%s] failed, trying next one. %s", ep.url, err.Error())
+		trace.SpanFromContext(ctx).AddEvent("failover", trace.WithAttributes(attribute.String("endpoint", ep.url)))
+		ep.setHealthy(false, 0)
+		p.reorder()
+	}
+
+	// nothing worked
+	if err == nil {
+		err = fmt.Errorf("no RPC end point available")
+	}
+	return err
+}
+
+// Execute the call on the client with the call timeout applied.
+func (p *Pool) try(ctx context.Context, c *ethrpc.Client, call func(context.Context, *ethrpc.Client) error) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	return call(ctx, c)
+}
+
+// Derive context with the call timeout, if set.
+func (p *Pool) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if 0 >= p.timeout {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.timeout)
+}
+
+// Get the current routing order of end points.
+func (p *Pool) order() []*endpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.routes
+}
+
+// Get the healthiest end point.
+func (p *Pool) best() *endpoint {
+	return p.order()[0]
+}
+
+// Check if we have at least one healthy end point.
+func (p *Pool) available() bool {
+	return p.best().isHealthy()
+}
+
+// Check end points health periodically until the pool is closed.
+func (p *Pool) monitor(interval time.Duration) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.check()
+		}
+	}
+}
+
+// Check height of all end points and update the routing order.
+func (p *Pool) check() {
+	// check all end points in parallel
+	var wg sync.WaitGroup
+	for _, ep := range p.endpoints {
+		wg.Add(1)
+		go func(ep *endpoint) {
+			defer wg.Done()
+			ep.check(p.dial, p.log)
+		}(ep)
+	}
+	wg.Wait()
+
+	// find the top height
+	var top uint64
+	for _, ep := range p.endpoints {
+		if h := ep.getHeight(); h > top {
+			top = h
+		}
+	}
+
+	// end points lagging behind are not healthy; the write end point is the only one able to sign, so we keep it
+	for _, ep := range p.endpoints {
+		if !ep.isHealthy() || ep.getHeight()+p.maxLag >= top {
+			continue
+		}
+
+		if ep == p.writer {
+			p.log.Warningf("RPC->Pool(): Write end point [%s] is out of sync at #%d, top is #%d.", ep.url, ep.getHeight(), top)
+			continue
+		}
+
+		p.log.Warningf("RPC->Pool(): End point [%s] is out of sync at #%d, top is #%d.", ep.url, ep.getHeight(), top)
+		ep.setHealthy(false, ep.getHeight())
+	}
+
+	p.reorder()
+}
+
+// Update the routing order of end points by their health.
+func (p *Pool) reorder() {
+	// healthy end points go first, higher blocks go first, configured order is kept otherwise
+	routes := make([]*endpoint, len(p.endpoints))
+	copy(routes, p.endpoints)
+	sort.SliceStable(routes, func(i, j int) bool {
+		hi, hj := routes[i].isHealthy(), routes[j].isHealthy()
+		if hi != hj {
+			return hi
+		}
+		return routes[i].getHeight() > routes[j].getHeight()
+	})
+
+	p.mu.Lock()
+	p.routes = routes
+	p.mu.Unlock()
+}
+
+// Check the end point; connect if not connected yet and get the current block height.
+func (ep *endpoint) check(dial Dialer, log services.Logger) {
+	// make sure we are connected
+	c := ep.getClient()
+	if c == nil {
+		var err error
+		c, err = dial(ep.url)
+		if err != nil {
+			log.Errorf("RPC->Pool(): Can not connect to [%s]. %s", ep.url, err.Error())
+			ep.setHealthy(false, 0)
+			return
+		}
+
+		ep.mu.Lock()
+		ep.client = c
+		ep.mu.Unlock()
+	}
+
+	// get the block height
+	ctx, cancel := context.WithTimeout(context.Background(), poolHealthCheckTimeout)
+	defer cancel()
+
+	var height hexutil.Uint64
+	if err := c.CallContext(ctx, &height, "eth_blockNumber"); err != nil {
+		log.Errorf("RPC->Pool(): End point [%s] health check failed. %s", ep.url, err.Error())
+		ep.setHealthy(false, 0)
+		return
+	}
+
+	ep.setHealthy(true, uint64(height))
+}
+
+// Get the client of the end point; nil if not connected.
+func (ep *endpoint) getClient() *ethrpc.Client {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return ep.client
+}
+
+// Get the last known block height of the end point.
+func (ep *endpoint) getHeight() uint64 {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return ep.height
+}
+
+// Check if the end point is healthy.
+func (ep *endpoint) isHealthy() bool {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return ep.healthy
+}
+
+// Update the end point health status.
+func (ep *endpoint) setHealthy(healthy bool, height uint64) {
+	ep.mu.Lock()
+	ep.healthy, ep.height = healthy, height
+	ep.mu.Unlock()
+}
+
+// Check if the error means we could not talk to the node.
+// Errors returned by the node itself, decoding errors and errors caused
+// by the caller giving up are not connection errors.
+func isConnectionError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	switch err.(type) {
+	case ethrpc.Error, *json.SyntaxError, *json.UnmarshalTypeError:
+		return false
+	}
+	return true
+}
+
+// Get logger tagged with the ID of the request the call belongs to.
+func (p *Pool) logger(ctx context.Context) services.Logger {
+	return services.ContextLogger(ctx, p.log)
+}
@@ -0,0 +1,29 @@
+package rpc_test
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/repository/rpc"
+	"github.com/op/go-logging"
+	"testing"
+	"time"
+)
+
+// The write end point holds the keys; lagging behind the read end points must not stop the writes.
+func TestPoolWriterLagging(t *testing.T) {
+	read := common.ReplayChainScheme + "testdata/opera.jsonl"
+	write := common.ReplayChainScheme + "testdata/lagging.jsonl"
+	pool, err := rpc.NewPool([]string{read, write}, write, 10, time.Hour, rpc.Dial, logging.MustGetLogger("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	var addr string
+	if err := pool.WriteCallContext(context.Background(), &addr, "personal_newAccount", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if addr != "0x5000000000000000000000000000000000000005" {
+		t.Errorf("unexpected address %s", addr)
+	}
+}
@@ -0,0 +1,357 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// position of the passphrase in params of calls unlocking accounts; passphrases never get to fixtures
+var recordSecretParams = map[string]int{
+	"personal_newAccount":      0,
+	"personal_unlockAccount":   1,
+	"personal_sendTransaction": 1,
+	"personal_signTransaction": 1,
+	"personal_sign":            2,
+}
+
+// value replacing passphrases in fixtures
+const recordRedacted = "***"
+
+// Define function connecting to a block-chain node end point.
+type Dialer func(url string) (*ethrpc.Client, error)
+
+// Define JSON-RPC message as sent over the wire; both calls and responses use it.
+type rpcMessage struct {
+	Version string          `json:"jsonrpc,omitempty"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// Define single call stored in a fixture file; the file holds one record per line.
+type fixtureRecord struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// Connect to the end point; replay:// end points are served from their fixture file.
+func Dial(url string) (*ethrpc.Client, error) {
+	if strings.HasPrefix(url, common.ReplayChainScheme) {
+		rp, err := NewReplayer(strings.TrimPrefix(url, common.ReplayChainScheme))
+		if err != nil {
+			return nil, err
+		}
+		return ethrpc.DialHTTPWithClient(url, &http.Client{Transport: rp})
+	}
+	return ethrpc.Dial(url)
+}
+
+// HTTP transport writing every JSON-RPC call and its response to a fixture file.
+// Batches are stored as separate calls so they can be replayed either way.
+type Recorder struct {
+	log       services.Logger
+	transport http.RoundTripper
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Create new recorder appending calls to the given fixture file.
+func NewRecorder(path string, log services.Logger) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Noticef("NewRecorder(): Recording RPC calls to %s.", path)
+	return &Recorder{log: log, transport: http.DefaultTransport, file: file}, nil
+}
+
+// Connect to the HTTP end point through the recorder.
+func (rec *Recorder) Dial(url string) (*ethrpc.Client, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("can not record calls to [%s], only HTTP end points are supported", url)
+	}
+	return ethrpc.DialHTTPWithClient(url, &http.Client{Transport: rec})
+}
+
+// Pass the request to the node and record calls it answered.
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	// keep the calls
+	calls, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// ask the node
+	res, err := rec.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the responses; the body is closed already if it can not be read
+	answers, err := readBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// failed HTTP requests are not recorded
+	if res.StatusCode != http.StatusOK {
+		return res, nil
+	}
+
+	rec.record(calls, answers)
+	return res, nil
+}
+
+// Close the fixture file; calls made after that are not recorded.
+func (rec *Recorder) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.file == nil {
+		return nil
+	}
+
+	err := rec.file.Close()
+	rec.file = nil
+	return err
+}
+
+// Pair calls with their responses and append them to the fixture file.
+func (rec *Recorder) record(calls []byte, answers []byte) {
+	req, _, err := decodeMessages(calls)
+	if err != nil {
+		rec.log.Errorf("Recorder(): Can not decode calls. %s", err.Error())
+		return
+	}
+	res, _, err := decodeMessages(answers)
+	if err != nil {
+		rec.log.Errorf("Recorder(): Can not decode responses. %s", err.Error())
+		return
+	}
+
+	// index responses by the call ID
+	byId := make(map[string]*rpcMessage, len(res))
+	for _, msg := range res {
+		byId[string(msg.Id)] = msg
+	}
+
+	// build the records
+	var buf bytes.Buffer
+	for _, call := range req {
+		msg, ok := byId[string(call.Id)]
+		if !ok {
+			continue
+		}
+
+		line, err := json.Marshal(&fixtureRecord{Method: call.Method, Params: redactParams(call.Method, call.Params), Result: msg.Result, Error: msg.Error})
+		if err != nil {
+			rec.log.Errorf("Recorder(): Can not encode %s call. %s", call.Method, err.Error())
+			continue
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	// write them at once so concurrent calls don't mix
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file == nil {
+		rec.log.Warningf("Recorder(): Recording closed, %d bytes of calls dropped.", buf.Len())
+		return
+	}
+	if _, err := rec.file.Write(buf.Bytes()); err != nil {
+		rec.log.Errorf("Recorder(): Can not write fixture. %s", err.Error())
+	}
+}
+
+// HTTP transport answering JSON-RPC calls from a fixture file instead of a node.
+// Repeated calls get the recorded responses in order, the last one is repeated when they run out.
+type Replayer struct {
+	mu      sync.Mutex
+	records map[string][]*fixtureRecord
+}
+
+// Create new replayer serving calls recorded in the given fixture file.
+func NewReplayer(path string) (*Replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// load the records, one per line; empty lines and comments are skipped
+	rp := &Replayer{records: make(map[string][]*fixtureRecord)}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if 0 == len(text) || '#' == text[0] {
+			continue
+		}
+
+		var rec fixtureRecord
+		if err := json.Unmarshal(text, &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, line, err.Error())
+		}
+
+		key := callKey(rec.Method, rec.Params)
+		rp.records[key] = append(rp.records[key], &rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rp, nil
+}
+
+// Answer the calls of the request from the fixture.
+func (rp *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	calls, batch, err := decodeMessages(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// answer the calls
+	answers := make([]*rpcMessage, len(calls))
+	for i, call := range calls {
+		answers[i] = rp.answer(call)
+	}
+
+	// respond the same way we were asked
+	var data []byte
+	if batch {
+		data, err = json.Marshal(answers)
+	} else {
+		data, err = json.Marshal(answers[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// Get the recorded response of the call.
+func (rp *Replayer) answer(call *rpcMessage) *rpcMessage {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	// do we have it?
+	key := callKey(call.Method, call.Params)
+	list, ok := rp.records[key]
+	if !ok {
+		msg, _ := json.Marshal(map[string]interface{}{
+			"code":    -32000,
+			"message": fmt.Sprintf("no recorded response for %s %s", call.Method, string(call.Params)),
+		})
+		return &rpcMessage{Version: "2.0", Id: call.Id, Error: msg}
+	}
+
+	// take the next one, keep the last one
+	rec := list[0]
+	if 1 < len(list) {
+		rp.records[key] = list[1:]
+	}
+
+	// calls without a result still need one
+	res := &rpcMessage{Version: "2.0", Id: call.Id, Result: rec.Result, Error: rec.Error}
+	if nil == res.Result && nil == res.Error {
+		res.Result = json.RawMessage("null")
+	}
+	return res
+}
+
+// Read the whole body and replace it so it can be read again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+
+	*body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// Decode single JSON-RPC message, or a batch of them.
+func decodeMessages(data []byte) ([]*rpcMessage, bool, error) {
+	data = bytes.TrimSpace(data)
+	if 0 < len(data) && '[' == data[0] {
+		var list []*rpcMessage
+		err := json.Unmarshal(data, &list)
+		return list, true, err
+	}
+
+	var msg rpcMessage
+	err := json.Unmarshal(data, &msg)
+	return []*rpcMessage{&msg}, false, err
+}
+
+// Replace the passphrase in params of the call, if any.
+func redactParams(method string, params json.RawMessage) json.RawMessage {
+	// does the call have a secret?
+	ix, ok := recordSecretParams[method]
+	if !ok {
+		return params
+	}
+
+	var list []interface{}
+	if err := json.Unmarshal(params, &list); err != nil || ix >= len(list) {
+		return params
+	}
+
+	list[ix] = recordRedacted
+	data, err := json.Marshal(list)
+	if err != nil {
+		return params
+	}
+	return data
+}
+
+// Get the key identifying the call in the fixture; params are compared by their JSON value, not formatting.
+// Passphrases are redacted the same way they are when recorded.
+func callKey(method string, params json.RawMessage) string {
+	var val interface{}
+	if err := json.Unmarshal(redactParams(method, params), &val); err != nil || nil == val {
+		val = []interface{}{}
+	}
+
+	canon, _ := json.Marshal(val)
+	return method + " " + string(canon)
+}
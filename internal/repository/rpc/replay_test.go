@@ -0,0 +1,327 @@
+package rpc_test
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/repository/rpc"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/op/go-logging"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hashes of calls recorded in testdata/opera.jsonl
+const (
+	operaBlock         = "0x00001f4b0000015d8c4b4a5f4f1a4c6b2f6e7d8c9b0a1f2e3d4c5b6a79808172"
+	operaMinedTx       = "0x5a1a2c6e1f0f4c52b1b5d5a3d4d55b7ae2b8c0d1e2f3a4b5c6d7e8f9a0b1c2d3"
+	operaContractTx    = "0x7c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d"
+	operaPendingTx     = "0x9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d"
+	operaZeroPendingTx = "0x1f2e3d4c5b6a79808172635445362718091a2b3c4d5e6f708192a3b4c5d6e7f8"
+	operaUnknownTx     = "0x0000000000000000000000000000000000000000000000000000000000000bad"
+	operaUnknownBlock  = "0x0000000000000000000000000000000000000000000000000000000000000bad"
+)
+
+// Silence the logger, tests report what they need.
+func TestMain(m *testing.M) {
+	logging.SetBackend(logging.NewLogBackend(ioutil.Discard, "", 0))
+	os.Exit(m.Run())
+}
+
+// Create RPC adapter served by the fixture instead of a node.
+func newReplayRpc(t *testing.T, fixture string) rpc.BlockChain {
+	cfg := &common.Config{RpcUrl: common.ReplayChainScheme + fixture, RpcHealthInterval: time.Hour}
+	bc, err := rpc.NewRpc(cfg, logging.MustGetLogger("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bc
+}
+
+// Check the amount has expected value in WEI.
+func checkAmount(t *testing.T, field string, got models.Amount, want string) {
+	t.Helper()
+	if got.String() != want {
+		t.Errorf("%s: expected %s, got %s", field, want, got.String())
+	}
+}
+
+func TestTransactionByHashMined(t *testing.T) {
+	bc := newReplayRpc(t, "testdata/opera.jsonl")
+
+	tx, err := bc.TransactionByHash(context.Background(), operaMinedTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tx.Hash != operaMinedTx || tx.From != "0x8a8e4d5f2f6c0f0b6e1b3c0a7e6d4f2a1b9c8d7e" || tx.Input != "0x" || tx.Nonce != 42 {
+		t.Errorf("unexpected transaction %+v", tx)
+	}
+	if tx.To == nil || *tx.To != "0x1c6a3b2e5d4f7a8b9c0d1e2f3a4b5c6d7e8f9a0b" {
+		t.Errorf("unexpected recipient %v", tx.To)
+	}
+	if tx.BlockHash == nil || *tx.BlockHash != operaBlock {
+		t.Errorf("unexpected block %v", tx.BlockHash)
+	}
+	if tx.TxIndex == nil || *tx.TxIndex != 3 {
+		t.Errorf("unexpected index %v", tx.TxIndex)
+	}
+
+	checkAmount(t, "value", tx.Value, "1000000000000000000")
+	checkAmount(t, "gas limit", tx.GasLimit, "21000")
+	checkAmount(t, "gas used", tx.GasUsed, "21000")
+	checkAmount(t, "gas price", tx.GasPrice, "1000000000")
+	checkAmount(t, "fee", tx.Fee, "21000000000000")
+}
+
+func TestTransactionByHashContractCreation(t *testing.T) {
+	bc := newReplayRpc(t, "testdata/opera.jsonl")
+
+	tx, err := bc.TransactionByHash(context.Background(), operaContractTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tx.To != nil {
+		t.Errorf("expected no recipient, got %s", *tx.To)
+	}
+	checkAmount(t, "value", tx.Value, "0")
+	checkAmount(t, "gas limit", tx.GasLimit, "3000000")
+	checkAmount(t, "gas used", tx.GasUsed, "118024")
+	checkAmount(t, "fee", tx.Fee, "118024000000000")
+}
+
+func TestTransactionByHashPending(t *testing.T) {
+	bc := newReplayRpc(t, "testdata/opera.jsonl")
+
+	// both ways nodes report pending transactions; a receipt is never asked for,
+	// the fixture doesn't have any for them
+	for _, hash := range []string{operaPendingTx, operaZeroPendingTx} {
+		tx, err := bc.TransactionByHash(context.Background(), hash)
+		if err != nil {
+			t.Fatalf("%s: %s", hash, err.Error())
+		}
+
+		if tx.Hash != hash {
+			t.Errorf("%s: unexpected hash %s", hash, tx.Hash)
+		}
+		if tx.BlockHash != nil || tx.TxIndex != nil {
+			t.Errorf("%s: expected no block, got %v at %v", hash, tx.BlockHash, tx.TxIndex)
+		}
+		checkAmount(t, hash+" gas used", tx.GasUsed, "0")
+		checkAmount(t, hash+" fee", tx.Fee, "0")
+	}
+}
+
+func TestTransactionByHashNodeError(t *testing.T) {
+	bc := newReplayRpc(t, "testdata/opera.jsonl")
+
+	_, err := bc.TransactionByHash(context.Background(), "0x12")
+	if err == nil || !strings.Contains(err.Error(), "hex string has length 2") {
+		t.Fatalf("expected node error, got %v", err)
+	}
+	if _, ok := err.(ethrpc.Error); !ok {
+		t.Errorf("expected error of the node, got %T", err)
+	}
+}
+
+func TestTransactionsByHash(t *testing.T) {
+	bc := newReplayRpc(t, "testdata/opera.jsonl")
+
+	hashes := []string{operaPendingTx, operaMinedTx, operaUnknownTx, operaZeroPendingTx}
+	txs, errs := bc.TransactionsByHash(context.Background(), hashes)
+
+	for i, hash := range hashes {
+		if hash == operaUnknownTx {
+			if errs[i] == nil || txs[i] != nil {
+				t.Errorf("expected unknown transaction error, got %v", errs[i])
+			}
+			continue
+		}
+
+		if errs[i] != nil {
+			t.Fatalf("%s: %s", hash, errs[i].Error())
+		}
+		if txs[i].Hash != hash {
+			t.Errorf("expected %s at %d, got %s", hash, i, txs[i].Hash)
+		}
+	}
+
+	// the receipt of the mined transaction is loaded in a batch too
+	checkAmount(t, "fee", txs[1].Fee, "21000000000000")
+	if txs[3].BlockHash != nil {
+		t.Errorf("expected pending transaction, got block %s", *txs[3].BlockHash)
+	}
+}
+
+func TestBlockByHash(t *testing.T) {
+	bc := newReplayRpc(t, "testdata/opera.jsonl")
+
+	blk, err := bc.BlockByHash(context.Background(), operaBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	number := hexutil.Big(blk.Number)
+	if blk.Hash != operaBlock || number.ToInt().Uint64() != 0x1d8a3b {
+		t.Errorf("unexpected block %s #%s", blk.Hash, number.String())
+	}
+	if !blk.TimeStamp.Equal(time.Unix(0x5f5e3a1c, 0)) {
+		t.Errorf("unexpected time stamp %s", blk.TimeStamp.String())
+	}
+	if len(blk.TxHashes) != 2 || blk.TxHashes[0] != operaMinedTx || blk.TxHashes[1] != operaContractTx {
+		t.Errorf("unexpected transactions %v", blk.TxHashes)
+	}
+}
+
+func TestPendingBalance(t *testing.T) {
+	bc := newReplayRpc(t, "testdata/opera.jsonl")
+
+	balance, err := bc.PendingBalance(context.Background(), "0x8a8e4d5f2f6c0f0b6e1b3c0a7e6d4f2a1b9c8d7e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAmount(t, "balance", *balance, "1000000000000000000")
+}
+
+func TestBlockByHashUnknown(t *testing.T) {
+	bc, err := rpc.NewCache(newReplayRpc(t, "testdata/opera.jsonl"), 10, logging.MustGetLogger("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the null answer is an error every time, it's never cached
+	for i := 0; i < 2; i++ {
+		blk, err := bc.BlockByHash(context.Background(), operaUnknownBlock)
+		if err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Fatalf("expected block not found, got %v", blk)
+		}
+	}
+}
+
+func TestTransactionByHashUnknown(t *testing.T) {
+	bc := newReplayRpc(t, "testdata/opera.jsonl")
+
+	tx, err := bc.TransactionByHash(context.Background(), operaUnknownTx)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected transaction not found, got %v", tx)
+	}
+}
+
+// Define node API serving the recording test.
+type testNodeApi struct{}
+
+func (testNodeApi) BlockNumber() hexutil.Uint64 {
+	return 0x1d8a3c
+}
+
+func (testNodeApi) GetBalance(addr string, block string) *hexutil.Big {
+	return (*hexutil.Big)(hexutil.MustDecodeBig("0xde0b6b3a7640000"))
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	// run a node
+	srv := ethrpc.NewServer()
+	if err := srv.RegisterName("eth", testNodeApi{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.RegisterName("ftm", testNodeApi{}); err != nil {
+		t.Fatal(err)
+	}
+	node := httptest.NewServer(srv)
+	defer node.Close()
+
+	// record calls made to the node
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fixture := filepath.Join(dir, "record.jsonl")
+	cfg := &common.Config{RpcUrl: node.URL, RpcHealthInterval: time.Hour, RpcRecord: fixture}
+	bc, err := rpc.NewRpc(cfg, logging.MustGetLogger("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs := []string{"0x8a8e4d5f2f6c0f0b6e1b3c0a7e6d4f2a1b9c8d7e", "0x1c6a3b2e5d4f7a8b9c0d1e2f3a4b5c6d7e8f9a0b"}
+	if _, errs := bc.AccountBalances(context.Background(), addrs); errs[0] != nil || errs[1] != nil {
+		t.Fatalf("recording failed; %v", errs)
+	}
+	bc.(interface{ Close() }).Close()
+	node.Close()
+
+	// the batch was stored as single calls next to the health check
+	data, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Errorf("expected 3 recorded calls, got %d:\n%s", lines, data)
+	}
+
+	// replay them one by one without the node
+	bc = newReplayRpc(t, fixture)
+	for _, addr := range addrs {
+		bal, err := bc.AccountBalance(context.Background(), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkAmount(t, "balance", *bal, "1000000000000000000")
+	}
+}
+
+func TestRecorderSkipsFailedRequests(t *testing.T) {
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer node.Close()
+
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fixture := filepath.Join(dir, "record.jsonl")
+	rec, err := rpc.NewRecorder(fixture, logging.MustGetLogger("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed response is passed on without an error
+	req := httptest.NewRequest(http.MethodPost, node.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`))
+	req.RequestURI = ""
+	res, err := rec.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, res.StatusCode)
+	}
+
+	// closing twice is safe
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if 0 != len(data) {
+		t.Errorf("expected nothing recorded, got %s", data)
+	}
+}
@@ -1,38 +1,106 @@
 package rpc
 
 import (
+	"context"
 	"fantomrocks-api/internal/common"
 	"fantomrocks-api/internal/models"
 	"fantomrocks-api/internal/services"
-	"github.com/ethereum/go-ethereum/rpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // BlockChain adapter interface definitions
 type BlockChain interface {
-	AccountBalance(string) (*models.Amount, error)
-	TransactionByHash(string) (*models.BcTransaction, error)
-	BlockByHash(string) (*models.BcBlock, error)
-	TransferTokens(*models.Account, *models.Account, models.Amount) (*models.Transaction, error)
+	AccountBalance(context.Context, string) (*models.Amount, error)
+	AccountBalances(context.Context, []string) ([]*models.Amount, []error)
+	PendingBalance(context.Context, string) (*models.Amount, error)
+	TransactionByHash(context.Context, string) (*models.BcTransaction, error)
+	TransactionsByHash(context.Context, []string) ([]*models.BcTransaction, []error)
+	BlockByHash(context.Context, string) (*models.BcBlock, error)
+	BlockHeight(context.Context) (uint64, error)
+	TransferTokens(context.Context, *models.Account, *models.Account, models.Amount) (*models.Transaction, error)
+	NewAccount(ctx context.Context, password string) (string, error)
 }
 
 // Block-Chain RPC Adapter
 type Rpc struct {
 	log services.Logger
-	*rpc.Client
+	*Pool
+
+	// recorder of the traffic, if enabled
+	rec *Recorder
 }
 
 // Prepare RPC client to be used to access block-chain node through it's com interface.
+// The simulated block chain is used instead if the end point uses the sim:// scheme.
 func NewRpc(cfg *common.Config, log services.Logger) (BlockChain, error) {
+	// no node to connect to
+	if cfg.IsSimulatedChain() {
+		return NewSimulator(cfg, log)
+	}
+
+	// use the list of end points if configured
+	urls := cfg.RpcUrls
+	if 0 == len(urls) {
+		urls = []string{cfg.RpcUrl}
+	}
+
 	// log actions
-	log.Debugf("NewRpc(): Initializing RPC connection to Node [%s]", cfg.RpcUrl)
+	log.Debugf("NewRpc(): Initializing RPC connection to Nodes %v", urls)
 
-	// try to establish a connection
-	client, err := rpc.Dial(cfg.RpcUrl)
+	// record the traffic if requested
+	dial := Dial
+	var rec *Recorder
+	if "" != cfg.RpcRecord {
+		var err error
+		rec, err = NewRecorder(cfg.RpcRecord, log)
+		if err != nil {
+			log.Criticalf("Can not record RPC calls. %s", err.Error())
+			return nil, err
+		}
+		dial = rec.Dial
+	}
+
+	// try to establish connections
+	pool, err := NewPool(urls, cfg.RpcWriteUrl, cfg.RpcMaxLag, cfg.RpcHealthInterval, dial, log)
 	if err != nil {
 		log.Criticalf("Can not connect to Node RPC end point. %s", err.Error())
+		if rec != nil {
+			rec.Close()
+		}
 		return nil, err
 	}
+	pool.SetTimeouts(cfg.RpcTimeout, cfg.RpcRetries, cfg.RpcRetryBackoff)
+
+	log.Debugf("NewRpc(): RPC adapter ready on %v.", urls)
+	return &Rpc{log: log, Pool: pool, rec: rec}, nil
+}
+
+// Close connections to the nodes and the fixture file the calls are recorded to.
+func (rpc *Rpc) Close() {
+	rpc.Pool.Close()
+
+	// no calls are made past this point, the recording is complete
+	if rpc.rec != nil {
+		if err := rpc.rec.Close(); err != nil {
+			rpc.log.Errorf("Rpc->Close(): Recording not closed. %s", err.Error())
+		}
+	}
+}
+
+// Close the block-chain adapter, if it runs background work or holds connections.
+func closeChain(chain BlockChain) {
+	if c, ok := chain.(interface{ Close() }); ok {
+		c.Close()
+	}
+}
+
+// Get logger tagged with the ID of the request the call belongs to.
+func (rpc *Rpc) logger(ctx context.Context) services.Logger {
+	return services.ContextLogger(ctx, rpc.log)
+}
 
-	log.Debugf("NewRpc(): RPC adapter ready on [%s].", cfg.RpcUrl)
-	return &Rpc{log: log, Client: client}, nil
+// Start span of the RPC adapter method call.
+func (rpc *Rpc) trace(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return services.StartSpan(ctx, "RPC."+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
 }
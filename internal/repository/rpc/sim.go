@@ -0,0 +1,409 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/services"
+	"fmt"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// gas used by a plain tokens transfer and the gas price paid on the simulated chain
+const (
+	simTransferGas = 21000
+	simGasPrice    = 1000000000
+)
+
+// errors returned by the simulated chain; messages follow these of the node
+var (
+	errSimInsufficientFunds = errors.New("insufficient funds for gas * price + value")
+	errSimWrongPassword     = errors.New("could not decrypt key with given passphrase")
+)
+
+// Block-Chain adapter simulating the node in memory for development and tests.
+// Accounts not seen before start with the configured balance. Transfers are applied
+// to the pending state immediately and included in the next block mined on a timer;
+// like on the node, balances change once the block is mined.
+// No block is mined while there are no transactions pending.
+type Simulator struct {
+	log     services.Logger
+	initial *big.Int
+
+	mu        sync.RWMutex
+	balances  map[ethcommon.Address]*big.Int
+	pending   map[ethcommon.Address]*big.Int
+	nonces    map[ethcommon.Address]uint64
+	passwords map[ethcommon.Address]string
+	txs       map[ethcommon.Hash]*simTransaction
+	blocks    map[ethcommon.Hash]*simBlock
+	head      *simBlock
+	queue     []*simTransaction
+
+	// blocks are mined until the simulator is closed
+	stop   chan struct{}
+	done   chan struct{}
+	closed sync.Once
+}
+
+// Define transaction processed by the simulated chain.
+type simTransaction struct {
+	hash  ethcommon.Hash
+	from  ethcommon.Address
+	to    ethcommon.Address
+	value *big.Int
+	nonce uint64
+	block *simBlock
+	index int
+}
+
+// Define block mined by the simulated chain.
+type simBlock struct {
+	hash   ethcommon.Hash
+	number uint64
+	time   time.Time
+	txs    []*simTransaction
+}
+
+// Create new simulated block chain mining blocks in the configured interval.
+func NewSimulator(cfg *common.Config, log services.Logger) (*Simulator, error) {
+	// initial balance of accounts in WEI
+	initial := models.Amount{Decimal: decimal.NewFromFloat(cfg.RpcSimBalance).Shift(18)}
+
+	sim := &Simulator{
+		log:       log,
+		initial:   initial.ToBig(),
+		balances:  make(map[ethcommon.Address]*big.Int),
+		pending:   make(map[ethcommon.Address]*big.Int),
+		nonces:    make(map[ethcommon.Address]uint64),
+		passwords: make(map[ethcommon.Address]string),
+		txs:       make(map[ethcommon.Hash]*simTransaction),
+		blocks:    make(map[ethcommon.Hash]*simBlock),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	// make the genesis block and start mining
+	sim.mine()
+	go sim.run(cfg.RpcSimBlockTime)
+
+	log.Debugf("NewSimulator(): Simulated block chain ready, new block every %s.", cfg.RpcSimBlockTime)
+	return sim, nil
+}
+
+// Stop mining new blocks; the state mined so far stays readable.
+func (sim *Simulator) Close() {
+	sim.closed.Do(func() {
+		close(sim.stop)
+		<-sim.done
+	})
+}
+
+// Get logger tagged with the ID of the request the call belongs to.
+func (sim *Simulator) logger(ctx context.Context) services.Logger {
+	return services.ContextLogger(ctx, sim.log)
+}
+
+// Get Account balance from the last block mined by the simulated chain.
+func (sim *Simulator) AccountBalance(ctx context.Context, addr string) (*models.Amount, error) {
+	adr, err := simAddress(addr)
+	if err != nil {
+		sim.logger(ctx).Errorf("Simulator->AccountBalance(): Error [%s]", err.Error())
+		return &models.Amount{}, err
+	}
+
+	sim.mu.RLock()
+	defer sim.mu.RUnlock()
+	return &models.Amount{Decimal: decimal.NewFromBigInt(sim.balanceOf(sim.balances, adr), 0)}, nil
+}
+
+// Get Account balance including the transactions not mined yet.
+func (sim *Simulator) PendingBalance(ctx context.Context, addr string) (*models.Amount, error) {
+	adr, err := simAddress(addr)
+	if err != nil {
+		sim.logger(ctx).Errorf("Simulator->PendingBalance(): Error [%s]", err.Error())
+		return &models.Amount{}, err
+	}
+
+	sim.mu.RLock()
+	defer sim.mu.RUnlock()
+	return &models.Amount{Decimal: decimal.NewFromBigInt(sim.balanceOf(sim.pending, adr), 0)}, nil
+}
+
+// Get balances of given list of Accounts from the simulated chain.
+func (sim *Simulator) AccountBalances(ctx context.Context, addrs []string) ([]*models.Amount, []error) {
+	res := make([]*models.Amount, len(addrs))
+	errs := make([]error, len(addrs))
+	for i, addr := range addrs {
+		res[i], errs[i] = sim.AccountBalance(ctx, addr)
+	}
+	return res, errs
+}
+
+// Get a raw Transaction information for given tx hash.
+func (sim *Simulator) TransactionByHash(ctx context.Context, hash string) (*models.BcTransaction, error) {
+	sim.mu.RLock()
+	defer sim.mu.RUnlock()
+
+	// do we know the transaction?
+	tx, ok := sim.txs[ethcommon.HexToHash(hash)]
+	if !ok {
+		err := fmt.Errorf("transaction %s not found", hash)
+		sim.logger(ctx).Errorf("Simulator->TransactionByHash(): Error! %s", err.Error())
+		return nil, err
+	}
+
+	return tx.bcTransaction(), nil
+}
+
+// Get raw Transactions information for given list of tx hashes.
+func (sim *Simulator) TransactionsByHash(ctx context.Context, hashes []string) ([]*models.BcTransaction, []error) {
+	res := make([]*models.BcTransaction, len(hashes))
+	errs := make([]error, len(hashes))
+	for i, h := range hashes {
+		res[i], errs[i] = sim.TransactionByHash(ctx, h)
+	}
+	return res, errs
+}
+
+// Get a raw Block information for given block hash.
+func (sim *Simulator) BlockByHash(ctx context.Context, hash string) (*models.BcBlock, error) {
+	sim.mu.RLock()
+	defer sim.mu.RUnlock()
+
+	// do we know the block?
+	blk, ok := sim.blocks[ethcommon.HexToHash(hash)]
+	if !ok {
+		err := fmt.Errorf("block %s not found", hash)
+		sim.logger(ctx).Errorf("Simulator->BlockByHash(): Error! %s", err.Error())
+		return nil, err
+	}
+
+	// collect transaction hashes
+	txs := make([]string, len(blk.txs))
+	for i, tx := range blk.txs {
+		txs[i] = tx.hash.Hex()
+	}
+
+	return &models.BcBlock{
+		Hash:      blk.hash.Hex(),
+		Number:    models.Number(*(*hexutil.Big)(new(big.Int).SetUint64(blk.number))),
+		TimeStamp: graphql.Time{Time: blk.time},
+		TxHashes:  txs,
+	}, nil
+}
+
+// Get the number of the most recent block mined.
+func (sim *Simulator) BlockHeight(_ context.Context) (uint64, error) {
+	sim.mu.RLock()
+	defer sim.mu.RUnlock()
+	return sim.head.number, nil
+}
+
+// Make a transfer of given amount of tokens between the accounts; the transaction stays pending until the next block.
+func (sim *Simulator) TransferTokens(ctx context.Context, fromAddr *models.Account, toAddr *models.Account, amount models.Amount) (*models.Transaction, error) {
+	sim.logger(ctx).Debugf("Simulator->TransferTokens(): Sending %s tokens [%d => %d]", amount.ToHex(), fromAddr.Id, toAddr.Id)
+
+	// make the transaction
+	tx, err := sim.send(fromAddr, toAddr, amount)
+	if err != nil {
+		sim.logger(ctx).Errorf("Simulator->TransferTokens(): Error! %s", err.Error())
+		return nil, err
+	}
+
+	sim.logger(ctx).Debugf("Simulator->TransferTokens(): Tx [%d => %d] pending %s", fromAddr.Id, toAddr.Id, tx.hash.Hex())
+	return &models.Transaction{
+		Id:          tx.hash.Hex(),
+		FromAccount: fromAddr,
+		ToAccount:   toAddr,
+		Amount:      &amount,
+		TimeStamp:   &graphql.Time{Time: time.Now()},
+	}, nil
+}
+
+// Create new account protected by the password and get its address.
+func (sim *Simulator) NewAccount(ctx context.Context, password string) (string, error) {
+	// random address is good enough, we never sign anything
+	var adr ethcommon.Address
+	if _, err := rand.Read(adr[:]); err != nil {
+		sim.logger(ctx).Errorf("Simulator->NewAccount(): Error [%s]", err.Error())
+		return "", err
+	}
+
+	sim.mu.Lock()
+	sim.passwords[adr] = password
+	sim.mu.Unlock()
+
+	addr := hexutil.Encode(adr[:])
+	sim.logger(ctx).Debugf("Simulator->NewAccount(): Account %s created.", addr)
+	return addr, nil
+}
+
+// Validate the transfer, update balances and nonce of the sender and queue the transaction for the next block.
+func (sim *Simulator) send(fromAddr *models.Account, toAddr *models.Account, amount models.Amount) (*simTransaction, error) {
+	from, err := simAddress(fromAddr.Address)
+	if err != nil {
+		return nil, err
+	}
+	to, err := simAddress(toAddr.Address)
+	if err != nil {
+		return nil, err
+	}
+	if amount.IsNegative() {
+		return nil, fmt.Errorf("invalid value %s", amount.String())
+	}
+
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	// accounts we created are protected by their password
+	if pwd, ok := sim.passwords[from]; ok && pwd != fromAddr.Password {
+		return nil, errSimWrongPassword
+	}
+
+	// can the sender pay for it? pending transactions count
+	value := amount.ToBig()
+	cost := simCost(value)
+	if sim.balance(sim.pending, from).Cmp(cost) < 0 {
+		return nil, errSimInsufficientFunds
+	}
+
+	// make the transaction
+	tx := &simTransaction{from: from, to: to, value: value, nonce: sim.nonces[from]}
+	tx.hash = crypto.Keccak256Hash(from[:], to[:], simWord(value), simWord(new(big.Int).SetUint64(tx.nonce)))
+
+	// apply it to the pending state
+	sim.pending[from].Sub(sim.pending[from], cost)
+	sim.balance(sim.pending, to).Add(sim.pending[to], value)
+	sim.nonces[from]++
+
+	sim.txs[tx.hash] = tx
+	sim.queue = append(sim.queue, tx)
+	return tx, nil
+}
+
+// Mine new blocks periodically until the simulator is closed.
+func (sim *Simulator) run(interval time.Duration) {
+	defer close(sim.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sim.stop:
+			return
+		case <-ticker.C:
+			sim.mine()
+		}
+	}
+}
+
+// Mine new block including all the pending transactions; empty blocks are not mined, except the genesis block.
+func (sim *Simulator) mine() {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	// anything to include?
+	if sim.head != nil && len(sim.queue) == 0 {
+		return
+	}
+
+	// chain the block to the current head
+	blk := &simBlock{time: time.Now(), txs: sim.queue}
+	var parent ethcommon.Hash
+	if sim.head != nil {
+		blk.number = sim.head.number + 1
+		parent = sim.head.hash
+	}
+
+	// hash the block content
+	data := [][]byte{parent[:], simWord(new(big.Int).SetUint64(blk.number)), simWord(big.NewInt(blk.time.UnixNano()))}
+	for i, tx := range blk.txs {
+		tx.block, tx.index = blk, i
+		data = append(data, tx.hash[:])
+
+		// the block changes the balances
+		sim.balance(sim.balances, tx.from).Sub(sim.balances[tx.from], simCost(tx.value))
+		sim.balance(sim.balances, tx.to).Add(sim.balances[tx.to], tx.value)
+	}
+	blk.hash = crypto.Keccak256Hash(data...)
+
+	sim.blocks[blk.hash] = blk
+	sim.head = blk
+	sim.queue = nil
+}
+
+// Get balance of the address in the given state for update; addresses not seen before get the initial balance.
+// The caller is expected to hold the write lock.
+func (sim *Simulator) balance(state map[ethcommon.Address]*big.Int, adr ethcommon.Address) *big.Int {
+	bal, ok := state[adr]
+	if !ok {
+		bal = new(big.Int).Set(sim.initial)
+		state[adr] = bal
+	}
+	return bal
+}
+
+// Get balance of the address in the given state without recording addresses not seen before; the value must not be modified.
+// The caller is expected to hold the read lock.
+func (sim *Simulator) balanceOf(state map[ethcommon.Address]*big.Int, adr ethcommon.Address) *big.Int {
+	if bal, ok := state[adr]; ok {
+		return bal
+	}
+	return sim.initial
+}
+
+// Get the value transferred plus the fee paid by the sender.
+func simCost(value *big.Int) *big.Int {
+	return new(big.Int).Add(value, big.NewInt(simTransferGas*simGasPrice))
+}
+
+// Build Blockchain Transaction entity the same way as if it was received from the node.
+// The caller is expected to hold the read lock.
+func (tx *simTransaction) bcTransaction() *models.BcTransaction {
+	to := hexutil.Encode(tx.to[:])
+	raw := rpcTransaction{
+		Hash:     tx.hash.Hex(),
+		From:     hexutil.Encode(tx.from[:]),
+		To:       &to,
+		Value:    hexutil.Big(*tx.value),
+		Input:    "0x",
+		Nonce:    hexutil.Uint(tx.nonce),
+		Gas:      hexutil.Big(*big.NewInt(simTransferGas)),
+		GasPrice: hexutil.Big(*big.NewInt(simGasPrice)),
+	}
+
+	// pending transactions don't have block and receipt
+	var rec *rpcReceipt
+	if tx.block != nil {
+		hash := tx.block.hash.Hex()
+		ix := hexutil.Uint(tx.index)
+		raw.BlockHash, raw.TxIndex = &hash, &ix
+		rec = &rpcReceipt{Gas: hexutil.Big(*big.NewInt(simTransferGas))}
+	}
+
+	return newBcTransaction(&raw, rec)
+}
+
+// Decode hex address the same way the node does.
+func simAddress(addr string) (ethcommon.Address, error) {
+	if !ethcommon.IsHexAddress(addr) {
+		return ethcommon.Address{}, fmt.Errorf("invalid address %q", addr)
+	}
+	return ethcommon.HexToAddress(addr), nil
+}
+
+// Encode the number as a fixed width 32 bytes word, so concatenated hash inputs can't be ambiguous.
+func simWord(v *big.Int) []byte {
+	return ethcommon.BigToHash(v).Bytes()
+}
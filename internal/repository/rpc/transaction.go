@@ -1,76 +1,205 @@
 package rpc
 
 import (
+	"context"
 	"fantomrocks-api/internal/models"
+	"fantomrocks-api/internal/services"
+	"fmt"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
 	"github.com/graph-gophers/graphql-go"
 	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
 	"math/big"
 	"time"
 )
 
+// define raw transaction structure as returned by the node
+type rpcTransaction struct {
+	Hash      string        `json:"hash"`
+	From      string        `json:"from"`
+	To        *string       `json:"to"`
+	Value     hexutil.Big   `json:"value"`
+	Input     string        `json:"input"`
+	Nonce     hexutil.Uint  `json:"nonce"`
+	Gas       hexutil.Big   `json:"gas"`
+	GasPrice  hexutil.Big   `json:"gasPrice"`
+	BlockHash *string       `json:"blockHash"`
+	TxIndex   *hexutil.Uint `json:"transactionIndex"`
+}
+
+// block hash reported for pending transactions by some node versions instead of null
+const pendingBlockHash = "0x0000000000000000000000000000000000000000000000000000000000000000"
+
+// Check if the transaction is still waiting for a block.
+func (raw *rpcTransaction) isPending() bool {
+	return raw.BlockHash == nil || *raw.BlockHash == pendingBlockHash
+}
+
+// define raw transaction receipt structure as returned by the node
+type rpcReceipt struct {
+	CumulativeGas hexutil.Big `json:"cumulativeGasUsed"`
+	Gas           hexutil.Big `json:"gasUsed"`
+}
+
 // Get a raw Transaction information for given tx hash.
-func (rpc *Rpc) TransactionByHash(hash string) (*models.BcTransaction, error) {
+func (rpc *Rpc) TransactionByHash(ctx context.Context, hash string) (*models.BcTransaction, error) {
+	// trace the call
+	ctx, span := rpc.trace(ctx, "TransactionByHash", attribute.String("tx.hash", hash))
+	defer span.End()
+
 	// unlock the source account
-	rpc.log.Debugf("RPC->TransactionByHash(): Loading tx details for [%s]", hash)
+	rpc.logger(ctx).Debugf("RPC->TransactionByHash(): Loading tx details for [%s]", hash)
 
 	// container for raw data
-	var raw struct {
-		Hash      string        `json:"hash"`
-		From      string        `json:"from"`
-		To        *string       `json:"to"`
-		Value     hexutil.Big   `json:"value"`
-		Input     string        `json:"input"`
-		Nonce     hexutil.Uint  `json:"nonce"`
-		Gas       hexutil.Big   `json:"gas"`
-		GasPrice  hexutil.Big   `json:"gasPrice"`
-		BlockHash *string       `json:"blockHash"`
-		TxIndex   *hexutil.Uint `json:"transactionIndex"`
-	}
+	var raw rpcTransaction
 
 	// call for data
-	err := rpc.Call(&raw, "ftm_getTransactionByHash", hash)
+	err := rpc.CallContext(ctx, &raw, "ftm_getTransactionByHash", hash)
 	if err != nil {
-		rpc.log.Errorf("RPC->TransactionByHash(): Error! %s", err.Error())
+		rpc.logger(ctx).Errorf("RPC->TransactionByHash(): Error! %s", err.Error())
+		services.SpanError(span, err)
 		return nil, err
 	}
 
-	// get decoded base value
-	value := big.Int(raw.Value)
-
-	// get fee and gas related calculations
-	var fee big.Int
-	var gas big.Int
-	gp := big.Int(raw.GasPrice)
-	gl := big.Int(raw.Gas)
+	// the node answers null for unknown transactions
+	if raw.Hash == "" {
+		err = fmt.Errorf("transaction %s not found", hash)
+		rpc.logger(ctx).Errorf("RPC->TransactionByHash(): Error! %s", err.Error())
+		services.SpanError(span, err)
+		return nil, err
+	}
 
 	// is there a block? get the receipt if we can
-	if raw.BlockHash != nil {
+	var rec *rpcReceipt
+	if !raw.isPending() {
 		// get transaction receipt
-		var rec struct {
-			CumulativeGas hexutil.Big `json:"cumulativeGasUsed"`
-			Gas           hexutil.Big `json:"gasUsed"`
-		}
+		rec = new(rpcReceipt)
 
 		// call for data
-		err := rpc.Call(&rec, "eth_getTransactionReceipt", hash)
+		err := rpc.CallContext(ctx, rec, "eth_getTransactionReceipt", hash)
 		if err != nil {
-			rpc.log.Errorf("RPC->TransactionByHash(): Error! %s", err.Error())
+			rpc.logger(ctx).Errorf("RPC->TransactionByHash(): Error! %s", err.Error())
+			services.SpanError(span, err)
 			return nil, err
 		}
+	}
+
+	// build and return the value
+	return newBcTransaction(&raw, rec), nil
+}
+
+// Get raw Transactions information for given list of tx hashes using batched calls.
+// The transactions are returned in the order of hashes; errors are reported per transaction.
+func (rpc *Rpc) TransactionsByHash(ctx context.Context, hashes []string) ([]*models.BcTransaction, []error) {
+	// trace the call
+	ctx, span := rpc.trace(ctx, "TransactionsByHash", attribute.Int("batch.size", len(hashes)))
+	defer span.End()
+
+	// inform
+	rpc.logger(ctx).Debugf("RPC->TransactionsByHash(): Loading %d transactions in a batch", len(hashes))
+
+	// prep the batch of transaction calls
+	raw := make([]rpcTransaction, len(hashes))
+	batch := make([]ethrpc.BatchElem, len(hashes))
+	for i, h := range hashes {
+		batch[i] = ethrpc.BatchElem{Method: "ftm_getTransactionByHash", Args: []interface{}{h}, Result: &raw[i]}
+	}
+
+	// make the call
+	res := make([]*models.BcTransaction, len(hashes))
+	errs := make([]error, len(hashes))
+	if err := rpc.BatchCallContext(ctx, batch); err != nil {
+		rpc.logger(ctx).Errorf("RPC->TransactionsByHash(): Error! %s", err.Error())
+		services.SpanError(span, err)
+		for i := range errs {
+			errs[i] = err
+		}
+		return res, errs
+	}
 
-		// calculate the fee
+	// prep the batch of receipt calls for transactions already in a block
+	recs := make([]*rpcReceipt, len(hashes))
+	recBatch := make([]ethrpc.BatchElem, 0, len(hashes))
+	recIndex := make([]int, 0, len(hashes))
+	for i, el := range batch {
+		// skip failed and unknown transactions
+		if el.Error != nil || raw[i].Hash == "" {
+			continue
+		}
+
+		// pending transactions don't have receipt
+		if !raw[i].isPending() {
+			recs[i] = new(rpcReceipt)
+			recBatch = append(recBatch, ethrpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []interface{}{hashes[i]}, Result: recs[i]})
+			recIndex = append(recIndex, i)
+		}
+	}
+
+	// get the receipts
+	if 0 < len(recBatch) {
+		if err := rpc.BatchCallContext(ctx, recBatch); err != nil {
+			rpc.logger(ctx).Errorf("RPC->TransactionsByHash(): Error! %s", err.Error())
+			services.SpanError(span, err)
+			for i := range errs {
+				errs[i] = err
+			}
+			return res, errs
+		}
+	}
+
+	// mark failed receipts
+	for j, el := range recBatch {
+		if el.Error != nil {
+			errs[recIndex[j]] = el.Error
+		}
+	}
+
+	// build the result set
+	for i, el := range batch {
+		switch {
+		case el.Error != nil:
+			errs[i] = el.Error
+		case raw[i].Hash == "":
+			errs[i] = fmt.Errorf("transaction %s not found", hashes[i])
+		case errs[i] == nil:
+			res[i] = newBcTransaction(&raw[i], recs[i])
+		}
+	}
+
+	return res, errs
+}
+
+// Build Blockchain Transaction entity from raw transaction and receipt (if any) data.
+func newBcTransaction(raw *rpcTransaction, rec *rpcReceipt) *models.BcTransaction {
+	// get decoded base value
+	value := big.Int(raw.Value)
+
+	// get fee and gas related calculations
+	var fee big.Int
+	var gas big.Int
+	gp := big.Int(raw.GasPrice)
+	gl := big.Int(raw.Gas)
+
+	// calculate the fee if we have the receipt
+	if rec != nil {
 		gas = big.Int(rec.Gas)
 		fee = *fee.Mul(&gp, &gas)
 	}
 
 	// index of the tx in the block (if any)
 	var ix *int32
-	if nil != raw.TxIndex {
+	if nil != raw.TxIndex && !raw.isPending() {
 		v := int32(*raw.TxIndex)
 		ix = &v
 	}
 
+	// pending transactions have no block
+	block := raw.BlockHash
+	if raw.isPending() {
+		block = nil
+	}
+
 	// build and return the value
 	return &models.BcTransaction{
 		Hash:      raw.Hash,
@@ -84,14 +213,18 @@ func (rpc *Rpc) TransactionByHash(hash string) (*models.BcTransaction, error) {
 		GasUsed:   models.Amount{Decimal: decimal.NewFromBigInt(&gas, 0)},
 		Fee:       models.Amount{Decimal: decimal.NewFromBigInt(&fee, 0)},
 		TxIndex:   ix,
-		BlockHash: raw.BlockHash,
-	}, nil
+		BlockHash: block,
+	}
 }
 
 // Make a transfer of given amount of tokens from source account address to destination account address using given source account credentials.
-func (rpc *Rpc) TransferTokens(fromAddr *models.Account, toAddr *models.Account, amount models.Amount) (*models.Transaction, error) {
+func (rpc *Rpc) TransferTokens(ctx context.Context, fromAddr *models.Account, toAddr *models.Account, amount models.Amount) (*models.Transaction, error) {
+	// trace the call
+	ctx, span := rpc.trace(ctx, "TransferTokens", attribute.Int64("tx.from", fromAddr.Id), attribute.Int64("tx.to", toAddr.Id))
+	defer span.End()
+
 	// unlock the source account
-	rpc.log.Debugf("RPC->TransferTokens(): Sending %s tokens [%d => %d]", amount.ToHex(), fromAddr.Id, toAddr.Id)
+	rpc.logger(ctx).Debugf("RPC->TransferTokens(): Sending %s tokens [%d => %d]", amount.ToHex(), fromAddr.Id, toAddr.Id)
 
 	// prep transaction details
 	tx := map[string]interface{}{
@@ -102,14 +235,15 @@ func (rpc *Rpc) TransferTokens(fromAddr *models.Account, toAddr *models.Account,
 
 	// perform the call
 	var txHash string
-	err := rpc.Call(&txHash, "personal_sendTransaction", tx, fromAddr.Password)
+	err := rpc.WriteCallContext(ctx, &txHash, "personal_sendTransaction", tx, fromAddr.Password)
 	if err != nil {
-		rpc.log.Errorf("RPC->TransferTokens(): Error! %s", err.Error())
+		rpc.logger(ctx).Errorf("RPC->TransferTokens(): Error! %s", err.Error())
+		services.SpanError(span, err)
 		return nil, err
 	}
 
 	// unlock the source account
-	rpc.log.Debugf("RPC->TransferTokens(): Tx [%d => %d] pending %s", fromAddr.Id, toAddr.Id, txHash)
+	rpc.logger(ctx).Debugf("RPC->TransferTokens(): Tx [%d => %d] pending %s", fromAddr.Id, toAddr.Id, txHash)
 
 	// return a valid
 	return &models.Transaction{
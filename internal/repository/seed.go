@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/models"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/shopspring/decimal"
+	mrand "math/rand"
+	"time"
+)
+
+// supported strategies of seeded accounts pairing
+const (
+	SeedPairingRandom     = "random"
+	SeedPairingSequential = "sequential"
+	SeedPairingRing       = "ring"
+)
+
+// Define result of accounts seeding.
+type SeedReport struct {
+	Created int
+	Funded  int
+	Paired  int
+}
+
+// Provision <count> test accounts named by the configured prefix, fund them from the treasury
+// up to the configured amount and group them into pairs by the configured strategy.
+// Accounts, funds and pairs already in place are kept, so the seeding can be repeated safely.
+func (repo *Repository) Seed(ctx context.Context, cfg *common.Config, count int) (*SeedReport, error) {
+	report := new(SeedReport)
+
+	// make sure we can fund the accounts; the amount is configured in FTM
+	amount := models.Amount{Decimal: decimal.NewFromFloat(cfg.SeedAmount).Shift(18)}
+	if amount.IsPositive() && "" == cfg.SeedTreasuryAddress {
+		return report, fmt.Errorf("seed.treasury_address is required to fund the accounts")
+	}
+
+	// get the accounts
+	accounts, err := repo.seedAccounts(ctx, cfg, count, report)
+	if err != nil {
+		return report, err
+	}
+
+	// fund them
+	if amount.IsPositive() {
+		if err := repo.fundAccounts(ctx, cfg, accounts, amount, report); err != nil {
+			return report, err
+		}
+	}
+
+	// pair them
+	return report, repo.pairAccounts(ctx, cfg.SeedPairing, accounts, report)
+}
+
+// Get seeded accounts; missing accounts are created in the node, or written straight to the node key store directory, if configured.
+func (repo *Repository) seedAccounts(ctx context.Context, cfg *common.Config, count int, report *SeedReport) ([]*models.Account, error) {
+	// what accounts do we have
+	known, err := repo.Db.AllAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*models.Account, len(known))
+	for _, acc := range known {
+		byName[acc.Name] = acc
+	}
+
+	// where to create new accounts; the node signs transfers, so the key store has to be its own
+	newAccount := repo.Rpc.NewAccount
+	if "" != cfg.SeedKeystore {
+		ks := keystore.NewKeyStore(cfg.SeedKeystore, keystore.StandardScryptN, keystore.StandardScryptP)
+		newAccount = func(_ context.Context, password string) (string, error) {
+			acc, err := ks.NewAccount(password)
+			if err != nil {
+				return "", err
+			}
+			return acc.Address.Hex(), nil
+		}
+	}
+
+	// loop the expected accounts
+	accounts := make([]*models.Account, 0, count)
+	for i := 1; i <= count; i++ {
+		// do we have the account already?
+		name := fmt.Sprintf("%s%03d", cfg.SeedPrefix, i)
+		if acc, ok := byName[name]; ok {
+			accounts = append(accounts, acc)
+			continue
+		}
+
+		// make new one
+		acc, err := repo.newSeedAccount(ctx, name, newAccount)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, acc)
+		report.Created++
+	}
+
+	return accounts, nil
+}
+
+// Create new account with random password and store it in the database.
+func (repo *Repository) newSeedAccount(ctx context.Context, name string, newAccount func(context.Context, string) (string, error)) (*models.Account, error) {
+	// make the password
+	pwd := make([]byte, 16)
+	if _, err := rand.Read(pwd); err != nil {
+		return nil, err
+	}
+
+	// create the account
+	acc := &models.Account{Name: name, Password: hex.EncodeToString(pwd)}
+	addr, err := newAccount(ctx, acc.Password)
+	if err != nil {
+		repo.Log.Errorf("Repository->Seed(): Can not create account %s. %s", name, err.Error())
+		return nil, err
+	}
+	acc.Address = addr
+
+	// keep it
+	ok, err := repo.Db.AddAccount(ctx, acc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("address %s of the new account %s already known", addr, name)
+	}
+
+	repo.Log.Noticef("Repository->Seed(): Account %s created as %s.", name, addr)
+	return acc, nil
+}
+
+// Top up balances of the accounts to the amount from the treasury.
+// Pending balances are used, so funds sent by a previous run and not mined yet are not sent again.
+func (repo *Repository) fundAccounts(ctx context.Context, cfg *common.Config, accounts []*models.Account, amount models.Amount, report *SeedReport) error {
+	treasury := &models.Account{Name: "treasury", Address: cfg.SeedTreasuryAddress, Password: cfg.SeedTreasuryPassword}
+
+	for _, acc := range accounts {
+		// do we need to fund the account?
+		balance, err := repo.Rpc.PendingBalance(ctx, acc.Address)
+		if err != nil {
+			return err
+		}
+		if balance.Cmp(amount.Decimal) >= 0 {
+			continue
+		}
+
+		// send the missing amount
+		missing := models.Amount{Decimal: amount.Sub(balance.Decimal)}
+		tx, err := repo.Rpc.TransferTokens(ctx, treasury, acc, missing)
+		if err != nil {
+			repo.Log.Errorf("Repository->Seed(): Can not fund account %s. %s", acc.Name, err.Error())
+			return err
+		}
+
+		repo.Log.Noticef("Repository->Seed(): Account %s funded with %s FTM by %s.", acc.Name, missing.ToFTM().String(), tx.Id)
+		report.Funded++
+	}
+
+	return nil
+}
+
+// Group the accounts into pairs by the strategy; known pairs are skipped.
+func (repo *Repository) pairAccounts(ctx context.Context, strategy string, accounts []*models.Account, report *SeedReport) error {
+	// which accounts are paired already
+	pairs, err := repo.Db.AllPairs(ctx)
+	if err != nil {
+		return err
+	}
+	paired := make(map[int64]bool, 2*len(pairs))
+	for _, p := range pairs {
+		paired[p.One.Id] = true
+		paired[p.Two.Id] = true
+	}
+
+	// add the pairs
+	for _, p := range seedPairs(strategy, accounts, paired) {
+		ok, err := repo.Db.AddPair(ctx, p[0].Id, p[1].Id)
+		if err != nil {
+			return err
+		}
+		if ok {
+			report.Paired++
+		}
+	}
+
+	return nil
+}
+
+// Make pairs of the accounts using the strategy.
+func seedPairs(strategy string, accounts []*models.Account, paired map[int64]bool) [][2]*models.Account {
+	res := make([][2]*models.Account, 0, len(accounts))
+
+	switch strategy {
+	case SeedPairingRing:
+		// each account is paired with the next one, the last one closes the ring
+		for i := range accounts {
+			j := (i + 1) % len(accounts)
+			if i < j || 2 < len(accounts) {
+				res = append(res, [2]*models.Account{accounts[i], accounts[j]})
+			}
+		}
+		return res
+
+	case SeedPairingRandom:
+		// accounts not paired yet are shuffled and paired in order
+		free := make([]*models.Account, 0, len(accounts))
+		for _, acc := range accounts {
+			if !paired[acc.Id] {
+				free = append(free, acc)
+			}
+		}
+		r := mrand.New(mrand.NewSource(time.Now().UnixNano()))
+		r.Shuffle(len(free), func(i, j int) { free[i], free[j] = free[j], free[i] })
+		accounts = free
+	}
+
+	// neighbours are paired; the odd account is left alone
+	for i := 0; i+1 < len(accounts); i += 2 {
+		res = append(res, [2]*models.Account{accounts[i], accounts[i+1]})
+	}
+	return res
+}
@@ -0,0 +1,78 @@
+package repository_test
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fantomrocks-api/internal/repository"
+	"github.com/op/go-logging"
+	"github.com/shopspring/decimal"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// treasury funding the seeded accounts on the simulated chain
+const seedTreasury = "0x7000000000000000000000000000000000000007"
+
+// Silence the logger, tests report what they need.
+func TestMain(m *testing.M) {
+	logging.SetBackend(logging.NewLogBackend(ioutil.Discard, "", 0))
+	os.Exit(m.Run())
+}
+
+// Seeding repeated before the funding transfers are mined must not fund the accounts again.
+func TestSeedRepeated(t *testing.T) {
+	// no block is mined during the test, the balances cache is on
+	cfg := &common.Config{
+		DbDriver:            "memory",
+		RpcUrl:              common.SimulatedChainScheme,
+		RpcSimBlockTime:     time.Hour,
+		RpcSimBalance:       1000,
+		BalanceTTL:          time.Hour,
+		SeedPrefix:          "seed-",
+		SeedAmount:          1200,
+		SeedPairing:         repository.SeedPairingSequential,
+		SeedTreasuryAddress: seedTreasury,
+	}
+	repo, err := repository.NewRepository(cfg, logging.MustGetLogger("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	// the first run creates, funds and pairs the accounts
+	ctx := context.Background()
+	report, err := repo.Seed(ctx, cfg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 2 || report.Funded != 2 || report.Paired != 1 {
+		t.Fatalf("unexpected first run %+v", report)
+	}
+	after, err := repo.Rpc.PendingBalance(ctx, seedTreasury)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second one finds everything in place
+	report, err = repo.Seed(ctx, cfg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 0 || report.Funded != 0 || report.Paired != 0 {
+		t.Errorf("unexpected second run %+v", report)
+	}
+
+	// the treasury paid 200 FTM for each account, and the fees, only once
+	bal, err := repo.Rpc.PendingBalance(ctx, seedTreasury)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bal.Equal(after.Decimal) {
+		t.Errorf("treasury debited again; %s, expected %s", bal.String(), after.String())
+	}
+	if paid := decimal.New(1000, 18).Sub(bal.Decimal); !paid.Equal(decimal.New(400, 18).Add(decimal.New(2*21000, 9))) {
+		t.Errorf("treasury paid %s", paid.String())
+	}
+}
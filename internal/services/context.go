@@ -0,0 +1,22 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// Define context keeping values of its parent, but not its deadline and cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+// Get context carrying the request values (ID, trace, identity) which is not cancelled with the request.
+// Writes use it so a client going away can not abort a transaction half way; they apply their own timeout.
+func DetachedContext(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool)          { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}                { return nil }
+func (detachedContext) Err() error                           { return nil }
+func (dc detachedContext) Value(key interface{}) interface{} { return dc.parent.Value(key) }
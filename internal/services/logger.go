@@ -27,21 +27,35 @@ type Logger interface {
 }
 
 // Get pre-configured logger with stderr output and leveled filtering.
+// Records are written as JSON lines if the JSON mode is enabled.
 func NewLogger(cfg *common.Config) *logging.Logger {
 	// prep the backend with configured formatting, use stderr for logging
 	backend := logging.NewLogBackend(os.Stderr, "", 0)
-	format := logging.MustStringFormatter(cfg.LogFormat)
+	var format logging.Formatter = jsonFormatter{}
+	if !cfg.LogJson {
+		format = logging.MustStringFormatter(cfg.LogFormat)
+	}
 	formattedBackend := logging.NewBackendFormatter(backend, format)
 
 	// make it leveled
-	leveledBackend := logging.AddModuleLevel(formattedBackend)
-	level, err := logging.LogLevel(cfg.LogLevel)
-	if err != nil {
-		level = logging.INFO
-	}
-	leveledBackend.SetLevel(level, "")
+	leveledBackend := &levelBackend{backend: formattedBackend}
+	leveledBackend.SetLevel(logLevel(cfg.LogLevel), "")
 
 	// assign the backend and return the new logger
 	logging.SetBackend(leveledBackend)
 	return logging.MustGetLogger(cfg.AppName)
 }
+
+// Change level of the logger created by NewLogger; records already being written are not affected.
+func SetLogLevel(level string) {
+	logging.SetLevel(logLevel(level), "")
+}
+
+// Get logging level of the given name; INFO is used for unknown names.
+func logLevel(name string) logging.Level {
+	level, err := logging.LogLevel(name)
+	if err != nil {
+		return logging.INFO
+	}
+	return level
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/op/go-logging"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// define single log line written in JSON mode
+type jsonLogLine struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Module    string `json:"module"`
+	Func      string `json:"func,omitempty"`
+	RequestId string `json:"request_id,omitempty"`
+	Message   string `json:"msg"`
+}
+
+// Define formatter writing log records as JSON objects, one per line.
+type jsonFormatter struct{}
+
+// Format the log record as a JSON line.
+func (jsonFormatter) Format(calldepth int, r *logging.Record, w io.Writer) error {
+	line := jsonLogLine{
+		Time:    r.Time.UTC().Format(time.RFC3339Nano),
+		Level:   r.Level.String(),
+		Module:  r.Module,
+		Message: r.Message(),
+	}
+
+	// get the calling function
+	if pc, _, _, ok := runtime.Caller(calldepth + 1); ok {
+		if f := runtime.FuncForPC(pc); f != nil {
+			line.Func = shortFuncName(f.Name())
+		}
+	}
+
+	// request scoped records carry the request ID as the first argument
+	if 0 < len(r.Args) {
+		if tag, ok := r.Args[0].(requestTag); ok {
+			line.RequestId = string(tag)
+			line.Message = strings.TrimPrefix(line.Message, tag.String()+" ")
+		}
+	}
+
+	// encode the line; the encoder adds the new line
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(&line)
+}
+
+// Get short name of the function without package path and receiver.
+func shortFuncName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
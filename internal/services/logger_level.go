@@ -0,0 +1,36 @@
+package services
+
+import (
+	"github.com/op/go-logging"
+	"sync/atomic"
+)
+
+// Define leveled logging backend with a single level for all the modules.
+// Unlike the go-logging module leveled backend, the level can be safely changed while logging.
+type levelBackend struct {
+	backend logging.Backend
+	level   int32
+}
+
+// Log the record if the level is enabled.
+func (b *levelBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	if !b.IsEnabledFor(level, rec.Module) {
+		return nil
+	}
+	return b.backend.Log(level, calldepth+1, rec)
+}
+
+// Get the current level; the module is ignored.
+func (b *levelBackend) GetLevel(string) logging.Level {
+	return logging.Level(atomic.LoadInt32(&b.level))
+}
+
+// Set new level; the module is ignored.
+func (b *levelBackend) SetLevel(level logging.Level, _ string) {
+	atomic.StoreInt32(&b.level, int32(level))
+}
+
+// Check if the level is enabled.
+func (b *levelBackend) IsEnabledFor(level logging.Level, module string) bool {
+	return level <= b.GetLevel(module)
+}
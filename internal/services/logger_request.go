@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"github.com/op/go-logging"
+)
+
+// Define request ID tag passed to the log records of a request.
+// Text formatters print it as a prefix of the message, JSON formatter makes it a separate field.
+type requestTag string
+
+// Format the tag as a message prefix.
+func (t requestTag) String() string {
+	return "[" + string(t) + "]"
+}
+
+// Define logger tagging all the records with the ID of the request being served.
+type requestLogger struct {
+	log Logger
+	tag requestTag
+}
+
+// Get logger for the request of the given context.
+// The base logger is returned if the context does not belong to an identified request.
+func ContextLogger(ctx context.Context, log Logger) Logger {
+	// do we have a request ID?
+	id, ok := ctx.Value(common.RequestIdContextKey{}).(string)
+	if !ok || "" == id {
+		return log
+	}
+
+	// the wrapper adds a call level; keep the calling function reported correctly
+	if l, ok := log.(*logging.Logger); ok {
+		log = &logging.Logger{Module: l.Module, ExtraCalldepth: l.ExtraCalldepth + 1}
+	}
+
+	return &requestLogger{log: log, tag: requestTag(id)}
+}
+
+// Prepend the request tag to the arguments.
+func (rl *requestLogger) args(args []interface{}) []interface{} {
+	return append([]interface{}{rl.tag}, args...)
+}
+
+func (rl *requestLogger) Fatal(args ...interface{}) { rl.log.Fatal(rl.args(args)...) }
+func (rl *requestLogger) Fatalf(format string, args ...interface{}) {
+	rl.log.Fatalf("%s "+format, rl.args(args)...)
+}
+func (rl *requestLogger) Panic(args ...interface{}) { rl.log.Panic(rl.args(args)...) }
+func (rl *requestLogger) Panicf(format string, args ...interface{}) {
+	rl.log.Panicf("%s "+format, rl.args(args)...)
+}
+func (rl *requestLogger) Critical(args ...interface{}) { rl.log.Critical(rl.args(args)...) }
+func (rl *requestLogger) Criticalf(format string, args ...interface{}) {
+	rl.log.Criticalf("%s "+format, rl.args(args)...)
+}
+func (rl *requestLogger) Error(args ...interface{}) { rl.log.Error(rl.args(args)...) }
+func (rl *requestLogger) Errorf(format string, args ...interface{}) {
+	rl.log.Errorf("%s "+format, rl.args(args)...)
+}
+func (rl *requestLogger) Warning(args ...interface{}) { rl.log.Warning(rl.args(args)...) }
+func (rl *requestLogger) Warningf(format string, args ...interface{}) {
+	rl.log.Warningf("%s "+format, rl.args(args)...)
+}
+func (rl *requestLogger) Notice(args ...interface{}) { rl.log.Notice(rl.args(args)...) }
+func (rl *requestLogger) Noticef(format string, args ...interface{}) {
+	rl.log.Noticef("%s "+format, rl.args(args)...)
+}
+func (rl *requestLogger) Info(args ...interface{}) { rl.log.Info(rl.args(args)...) }
+func (rl *requestLogger) Infof(format string, args ...interface{}) {
+	rl.log.Infof("%s "+format, rl.args(args)...)
+}
+func (rl *requestLogger) Debug(args ...interface{}) { rl.log.Debug(rl.args(args)...) }
+func (rl *requestLogger) Debugf(format string, args ...interface{}) {
+	rl.log.Debugf("%s "+format, rl.args(args)...)
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"errors"
+	"fantomrocks-api/internal/common"
+	"fmt"
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+// how long we keep buckets of clients we haven't heard from
+const rateLimitClientTTL = 10 * time.Minute
+
+// ErrExceedsBurst is returned for requests taking more tokens than the bucket can hold; such requests never pass.
+var ErrExceedsBurst = errors.New("request exceeds the rate limit burst")
+
+// Define error returned for requests over the rate limit; the request can be repeated after the wait.
+type RateLimitedError struct {
+	Wait time.Duration
+}
+
+// Get the error message.
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry in %s", e.Wait)
+}
+
+// Define rate limiter keeping token buckets of API clients.
+// Each client has a bucket for all the requests made and a separate, usually stricter,
+// bucket for the funds transfers.
+type RateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*clientBuckets
+	stop    chan struct{}
+	closed  sync.Once
+
+	// limits applied to clients
+	requestsRate   rate.Limit
+	requestsBurst  int
+	transfersRate  rate.Limit
+	transfersBurst int
+}
+
+// Define token buckets of a single client.
+type clientBuckets struct {
+	requests  *rate.Limiter
+	transfers *rate.Limiter
+	seen      time.Time
+}
+
+// Define quota of a single API client passed down the request context.
+type ClientQuota struct {
+	limiter *RateLimiter
+	client  string
+}
+
+// Create new rate limiter with limits from the configuration.
+func NewRateLimiter(cfg *common.Config) *RateLimiter {
+	rl := &RateLimiter{clients: make(map[string]*clientBuckets), stop: make(chan struct{})}
+	rl.SetLimits(cfg)
+
+	// drop idle clients periodically so the map does not grow forever
+	go rl.prune()
+	return rl
+}
+
+// Stop dropping idle clients; the limiter can still be used, but it keeps all the clients from now on.
+func (rl *RateLimiter) Close() {
+	rl.closed.Do(func() {
+		close(rl.stop)
+	})
+}
+
+// Apply limits from the configuration; buckets of known clients are updated too.
+func (rl *RateLimiter) SetLimits(cfg *common.Config) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.requestsRate = rate.Limit(cfg.RateLimitRequests)
+	rl.requestsBurst = cfg.RateLimitRequestsBurst
+	rl.transfersRate = rate.Limit(cfg.RateLimitTransfers)
+	rl.transfersBurst = cfg.RateLimitTransfersBurst
+
+	// update existing buckets
+	now := time.Now()
+	for _, cb := range rl.clients {
+		cb.requests.SetLimitAt(now, rl.requestsRate)
+		cb.requests.SetBurstAt(now, rl.requestsBurst)
+		cb.transfers.SetLimitAt(now, rl.transfersRate)
+		cb.transfers.SetBurstAt(now, rl.transfersBurst)
+	}
+}
+
+// Check if the client can make given number of requests; each operation of a batch counts as a request.
+// Returns RateLimitedError with the time to wait before the next attempt if the limit has been exceeded,
+// or ErrExceedsBurst if the number of requests can never be made at once.
+func (rl *RateLimiter) AllowRequests(client string, n int) error {
+	lim := rl.buckets(client).requests
+
+	// zero rate disables the limit
+	if 0 >= lim.Limit() || 0 >= n {
+		return nil
+	}
+	return reserve(lim, n)
+}
+
+// Check if the client can make given number of funds transfers.
+// Returns RateLimitedError with the time to wait before the next attempt if the limit has been exceeded,
+// or ErrExceedsBurst if the number of transfers can never be made at once.
+func (rl *RateLimiter) AllowTransfers(client string, n int) error {
+	lim := rl.buckets(client).transfers
+
+	// zero rate disables the limit
+	if 0 >= lim.Limit() {
+		return nil
+	}
+	return reserve(lim, n)
+}
+
+// Get quota of the given client.
+func (rl *RateLimiter) Quota(client string) *ClientQuota {
+	return &ClientQuota{limiter: rl, client: client}
+}
+
+// Check if the client of the quota can make given number of requests.
+func (q *ClientQuota) AllowRequests(n int) error {
+	return q.limiter.AllowRequests(q.client, n)
+}
+
+// Check if the client of the quota can make given number of funds transfers.
+func (q *ClientQuota) AllowTransfers(n int) error {
+	return q.limiter.AllowTransfers(q.client, n)
+}
+
+// Get token buckets of the given client; new buckets are created for unknown clients.
+func (rl *RateLimiter) buckets(client string) *clientBuckets {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// do we know the client?
+	cb, ok := rl.clients[client]
+	if !ok {
+		cb = &clientBuckets{
+			requests:  rate.NewLimiter(rl.requestsRate, rl.requestsBurst),
+			transfers: rate.NewLimiter(rl.transfersRate, rl.transfersBurst),
+		}
+		rl.clients[client] = cb
+	}
+
+	cb.seen = time.Now()
+	return cb
+}
+
+// Remove buckets of clients idle for too long, until the limiter is closed.
+func (rl *RateLimiter) prune() {
+	ticker := time.NewTicker(rateLimitClientTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rl.stop:
+			return
+		case <-ticker.C:
+		}
+
+		rl.mu.Lock()
+		for client, cb := range rl.clients {
+			if time.Since(cb.seen) > rateLimitClientTTL {
+				delete(rl.clients, client)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// Try to take n tokens from the bucket; the tokens are taken only if available right now.
+func reserve(lim *rate.Limiter, n int) error {
+	// requests larger than the bucket can never be fulfilled, there is no point to retry
+	if n > lim.Burst() {
+		return ErrExceedsBurst
+	}
+
+	// do we need to wait?
+	now := time.Now()
+	r := lim.ReserveN(now, n)
+	if !r.OK() {
+		return ErrExceedsBurst
+	}
+	if delay := r.DelayFrom(now); 0 < delay {
+		r.CancelAt(now)
+		return &RateLimitedError{Wait: delay}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fantomrocks-api/internal/common"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Define configuration reloader applying safe settings changes to running services.
+type ConfigReloader struct {
+	mu          sync.Mutex
+	cfg         *common.Config
+	load        func() (*common.Config, error)
+	log         Logger
+	subscribers []func(*common.Config)
+}
+
+// Create new configuration reloader starting with the given configuration
+// and using the load function to get the new one.
+func NewConfigReloader(cfg *common.Config, load func() (*common.Config, error), log Logger) *ConfigReloader {
+	return &ConfigReloader{cfg: cfg, load: load, log: log}
+}
+
+// Register a function applying the reloaded configuration.
+func (cr *ConfigReloader) OnReload(fn func(cfg *common.Config)) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.subscribers = append(cr.subscribers, fn)
+}
+
+// Reload the configuration and apply changes of settings safe to be changed at runtime.
+// Changes of other settings are logged and ignored; invalid configuration is not applied at all.
+func (cr *ConfigReloader) Reload() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	// load the new configuration
+	n, err := cr.load()
+	if err != nil {
+		cr.log.Errorf("ConfigReloader->Reload(): Can not reload configuration, keeping the current one. %s", err.Error())
+		return
+	}
+
+	// what changed
+	merged, applied, ignored := cr.cfg.Merge(n)
+	for _, name := range ignored {
+		cr.log.Warningf("ConfigReloader->Reload(): Change of %s ignored, restart the server to apply it.", name)
+	}
+	if 0 == len(applied) {
+		cr.log.Noticef("ConfigReloader->Reload(): No changes to apply.")
+		return
+	}
+
+	// apply the changes
+	for _, fn := range cr.subscribers {
+		fn(merged)
+	}
+	cr.cfg = merged
+	cr.log.Noticef("ConfigReloader->Reload(): Applied changes of %v.", applied)
+}
+
+// Reload the configuration each time the process receives SIGHUP signal.
+func (cr *ConfigReloader) WatchSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+
+	go func() {
+		for range sig {
+			cr.log.Noticef("ConfigReloader->WatchSignal(): SIGHUP received, reloading configuration.")
+			cr.Reload()
+		}
+	}()
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fantomrocks-api/internal/common"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// name of the tracer used for all the spans of the API server
+	tracerName = "fantomrocks-api"
+
+	// supported trace exporters
+	tracingExporterOtlp   = "otlp"
+	tracingExporterStdout = "stdout"
+)
+
+// Initialize tracing with the exporter from the configuration.
+// W3C trace context of incoming requests is propagated even if no exporter is configured,
+// spans are not recorded in that case. The returned function exports spans still buffered
+// and stops the exporter; call it before the process exits.
+func InitTracing(cfg *common.Config, log Logger) (func(context.Context) error, error) {
+	// we always propagate the trace context
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	// make the exporter
+	var exp sdktrace.SpanExporter
+	var err error
+	switch cfg.TracingExporter {
+	case "":
+		log.Debugf("InitTracing(): Tracing disabled.")
+		return func(context.Context) error { return nil }, nil
+	case tracingExporterStdout:
+		exp, err = stdouttrace.New()
+	case tracingExporterOtlp:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.TracingEndpoint)}
+		if cfg.TracingInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		err = fmt.Errorf("unknown trace exporter %s", cfg.TracingExporter)
+	}
+	if err != nil {
+		log.Criticalf("InitTracing(): Can not create trace exporter. %s", err.Error())
+		return nil, err
+	}
+
+	// make the provider; spans of traced callers are always sampled
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(cfg.AppName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+
+	log.Debugf("InitTracing(): Tracing to %s ready.", cfg.TracingExporter)
+	return tp.Shutdown, nil
+}
+
+// Start new span as a child of the span in the context, if any.
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// Mark the span failed with the given error.
+func SpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}